@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -15,13 +16,43 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Initialize AI agent with PostgreSQL connection
-	agent, err := ai.NewAIAgent(cfg.OpenAIKey, os.Getenv("POSTGRES_CONN_STRING"))
+	// Run maintenance subcommands instead of the bot
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			err := runMigrate(cfg, os.Args[2:])
+			if err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		case "reembed":
+			err := runReembed(cfg, os.Args[2:])
+			if err != nil {
+				log.Fatalf("Re-embedding failed: %v", err)
+			}
+			return
+		}
+	}
+
+	// Initialize AI agent with the configured LLM provider and PostgreSQL connection
+	agent, err := ai.NewAIAgent(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize AI agent: %v", err)
 	}
 	defer agent.Close()
 
+	// Rewrite memories embedded with a previous model in the background
+	if cfg.ReembedOnStart {
+		go func() {
+			updated, err := agent.Reembed(context.Background(), ai.DefaultReembedBatchSize)
+			if err != nil {
+				log.Printf("Error re-embedding conversations: %v", err)
+				return
+			}
+			log.Printf("Re-embedding finished, %d conversations updated", updated)
+		}()
+	}
+
 	// Initialize Discord bot
 	bot, err := discord.NewBot(cfg, agent)
 	if err != nil {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"tars-bot/internal/ai"
+	"tars-bot/internal/ai/vectorstore"
+	"tars-bot/internal/config"
+)
+
+const migrateUsage = "usage: tars-bot migrate up | down [steps] | status"
+
+// runMigrate implements the `migrate` subcommand for the PostgreSQL backend.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.MemoryBackend != "postgres" {
+		return fmt.Errorf("migrations only apply to the postgres memory backend, not %q", cfg.MemoryBackend)
+	}
+
+	// The chat provider resolves the embedding model when none is configured
+	chat, err := ai.NewChatProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	pool, err := vectorstore.NewPostgreSQLPool(cfg.PostgresConnString)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := vectorstore.NewMigrator(pool, vectorstore.MigrationParams{
+		EmbeddingDimensions: cfg.EmbeddingDimensions,
+		EmbeddingModel:      chat.EmbeddingModel(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			log.Printf("Reverted migration %d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
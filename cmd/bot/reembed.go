@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"tars-bot/internal/ai"
+	"tars-bot/internal/config"
+)
+
+// runReembed implements the `reembed [batch size]` subcommand, rewriting
+// stored embeddings with the configured embedding model.
+func runReembed(cfg *config.Config, args []string) error {
+	batchSize := ai.DefaultReembedBatchSize
+	if len(args) > 0 {
+		var err error
+		batchSize, err = strconv.Atoi(args[0])
+		if err != nil || batchSize < 1 {
+			return fmt.Errorf("invalid batch size %q", args[0])
+		}
+	}
+
+	agent, err := ai.NewAIAgent(cfg)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	// Stop cleanly between rows on CTRL+C; the job resumes on the next run
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	updated, err := agent.Reembed(ctx, batchSize)
+	log.Printf("%d conversations re-embedded with %s", updated, agent.Chat.EmbeddingModel())
+	return err
+}
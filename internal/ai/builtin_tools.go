@@ -0,0 +1,189 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"tars-bot/internal/ai/vectorstore"
+)
+
+var diceNotation = regexp.MustCompile(`^(\d*)d(\d+)([+-]\d+)?$`)
+
+// registerBuiltinTools adds the tools every deployment gets.
+func (a *AIAgent) registerBuiltinTools() error {
+	tools := []Tool{
+		{
+			Name:        "search_memory",
+			Description: "Search your long-term memory of past conversations in this server.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"query": {"type": "string", "description": "What to look for"},
+					"limit": {"type": "integer", "description": "Maximum number of memories to return", "minimum": 1, "maximum": 10}
+				},
+				"required": ["query"]
+			}`),
+			Handler: a.searchMemoryTool,
+		},
+		{
+			Name:        "current_time",
+			Description: "Get the current date and time.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"timezone": {"type": "string", "description": "IANA time zone such as Europe/Paris, defaults to UTC"}
+				}
+			}`),
+			Handler: currentTimeTool,
+		},
+		{
+			Name:        "roll_dice",
+			Description: "Roll dice using standard notation such as 1d20, 3d6 or 2d8+3.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"notation": {"type": "string", "description": "Dice notation, e.g. 2d6+1"}
+				},
+				"required": ["notation"]
+			}`),
+			Handler: rollDiceTool,
+		},
+	}
+
+	for _, tool := range tools {
+		err := a.Tools.Register(tool)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AIAgent) searchMemoryTool(ctx context.Context, inv ToolInvocation) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	err := json.Unmarshal(inv.Arguments, &args)
+	if err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Limit <= 0 || args.Limit > 10 {
+		args.Limit = 5
+	}
+
+	embedding, err := a.embed(ctx, args.Query)
+	if err != nil {
+		return "", err
+	}
+
+	conversations, err := a.Memory.SearchSimilar(ctx, vectorstore.SearchQuery{
+		GuildID:   inv.GuildID,
+		ChannelID: inv.ChannelID,
+		UserID:    inv.UserID,
+		Scope:     a.guildSettings(ctx, inv.GuildID).MemoryScope,
+		SessionID: inv.SessionID,
+		Embedding: embedding,
+		Limit:     args.Limit,
+
+		EmbeddingModel: a.Chat.EmbeddingModel(),
+		Mode:           a.SearchMode,
+		Text:           args.Query,
+		MinSimilarity:  a.MinSimilarity,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	type memory struct {
+		Date     string `json:"date"`
+		User     string `json:"user"`
+		Response string `json:"response"`
+	}
+	memories := make([]memory, 0, len(conversations))
+	for _, conv := range conversations {
+		memories = append(memories, memory{
+			Date:     conv.CreatedAt.Format(time.RFC3339),
+			User:     conv.Message,
+			Response: conv.Response,
+		})
+	}
+
+	result, err := json.Marshal(memories)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+func currentTimeTool(ctx context.Context, inv ToolInvocation) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	err := json.Unmarshal(inv.Arguments, &args)
+	if err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	location := time.UTC
+	if args.Timezone != "" {
+		location, err = time.LoadLocation(args.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %q", args.Timezone)
+		}
+	}
+
+	return time.Now().In(location).Format("Monday, 02 January 2006 15:04:05 MST"), nil
+}
+
+func rollDiceTool(ctx context.Context, inv ToolInvocation) (string, error) {
+	var args struct {
+		Notation string `json:"notation"`
+	}
+	err := json.Unmarshal(inv.Arguments, &args)
+	if err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	match := diceNotation.FindStringSubmatch(strings.ToLower(strings.ReplaceAll(args.Notation, " ", "")))
+	if match == nil {
+		return "", fmt.Errorf("invalid dice notation %q", args.Notation)
+	}
+
+	count := 1
+	if match[1] != "" {
+		count, _ = strconv.Atoi(match[1])
+	}
+	sides, _ := strconv.Atoi(match[2])
+	modifier := 0
+	if match[3] != "" {
+		modifier, _ = strconv.Atoi(match[3])
+	}
+	if count < 1 || count > 100 || sides < 2 || sides > 1000 {
+		return "", fmt.Errorf("use between 1 and 100 dice with 2 to 1000 sides")
+	}
+
+	rolls := make([]int, count)
+	total := modifier
+	for i := range rolls {
+		rolls[i] = rand.IntN(sides) + 1
+		total += rolls[i]
+	}
+
+	result, err := json.Marshal(map[string]interface{}{
+		"notation": args.Notation,
+		"rolls":    rolls,
+		"modifier": modifier,
+		"total":    total,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
@@ -7,7 +7,7 @@ import (
 
 type Memory struct {
 	mu      sync.Mutex
-	storage map[string][]models.Interaction // userID -> interactions
+	storage map[string][]models.Interaction // conversation key -> interactions
 }
 
 func NewMemory() *Memory {
@@ -16,38 +16,30 @@ func NewMemory() *Memory {
 	}
 }
 
-func (m *Memory) Store(userID, input, response string) {
+func (m *Memory) Store(key, input, response string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Limit to last 10 interactions to prevent memory bloat
-	if len(m.storage[userID]) >= 10 {
-		m.storage[userID] = append(m.storage[userID][1:], models.Interaction{
+	if len(m.storage[key]) >= 10 {
+		m.storage[key] = append(m.storage[key][1:], models.Interaction{
 			Input:    input,
 			Response: response,
 		})
 	} else {
-		m.storage[userID] = append(m.storage[userID], models.Interaction{
+		m.storage[key] = append(m.storage[key], models.Interaction{
 			Input:    input,
 			Response: response,
 		})
 	}
 }
 
-func (m *Memory) Retrieve(userID, currentMessage string) string {
+// Recent returns a copy of the latest interactions stored under key, oldest first.
+func (m *Memory) Recent(key string) []models.Interaction {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	interactions := m.storage[userID]
-	if len(interactions) == 0 {
-		return "No previous context. Current message: " + currentMessage
-	}
-
-	// Format the context
-	context := "Previous conversation context:\n"
-	for _, i := range interactions {
-		context += "User: " + i.Input + "\nAI: " + i.Response + "\n"
-	}
-
-	return context + "Current message: " + currentMessage
+	interactions := make([]models.Interaction, len(m.storage[key]))
+	copy(interactions, m.storage[key])
+	return interactions
 }
@@ -7,77 +7,127 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+
+	"tars-bot/pkg/models"
+)
+
+const (
+	DefaultBaseURL        = "https://api.openai.com/v1"
+	DefaultChatModel      = "gpt-3.5-turbo"
+	DefaultEmbeddingModel = "text-embedding-ada-002"
 )
 
 type ChatClient struct {
-	apiKey string
+	apiKey              string
+	baseURL             string
+	model               string
+	embeddingModel      string
+	embeddingDimensions int
 }
 
 func NewChatClient(apiKey string) *ChatClient {
-	return &ChatClient{apiKey: apiKey}
+	return NewCompatibleChatClient(DefaultBaseURL, apiKey, DefaultChatModel, DefaultEmbeddingModel, 0)
 }
 
-func (c *ChatClient) Completion(ctx context.Context, prompt string) (string, error) {
-	url := "https://api.openai.com/v1/chat/completions"
+// NewCompatibleChatClient creates a client for any server exposing the OpenAI
+// chat completions and embeddings API (Ollama, vLLM, LocalAI, ...). Empty
+// models fall back to the OpenAI defaults and an empty API key omits the
+// Authorization header. A positive embeddingDimensions asks models that
+// support shortening (text-embedding-3-*) for embeddings of that size.
+func NewCompatibleChatClient(baseURL, apiKey, model, embeddingModel string, embeddingDimensions int) *ChatClient {
+	if model == "" {
+		model = DefaultChatModel
+	}
+	if embeddingModel == "" {
+		embeddingModel = DefaultEmbeddingModel
+	}
 
-	reqBody := map[string]interface{}{
-		"model": "gpt-3.5-turbo",
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+	return &ChatClient{
+		apiKey:              apiKey,
+		baseURL:             strings.TrimRight(baseURL, "/"),
+		model:               model,
+		embeddingModel:      embeddingModel,
+		embeddingDimensions: embeddingDimensions,
 	}
+}
+
+// EmbeddingModel returns the name of the model used by CreateEmbedding.
+func (c *ChatClient) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// Completion returns the assistant's reply to messages. When tools are given
+// the reply may hold tool calls instead of content.
+func (c *ChatClient) Completion(ctx context.Context, messages []models.ChatMessage, tools []models.ToolDefinition) (models.ChatMessage, error) {
+	url := c.baseURL + "/chat/completions"
+
+	reqBody := c.completionRequest(messages, tools)
 
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return models.ChatMessage{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return models.ChatMessage{}, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return models.ChatMessage{}, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return models.ChatMessage{}, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
 		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
+			Message models.ChatMessage `json:"message"`
 		} `json:"choices"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return models.ChatMessage{}, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+		return models.ChatMessage{}, fmt.Errorf("no choices in response")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	reply := result.Choices[0].Message
+	reply.Role = models.RoleAssistant
+	return reply, nil
+}
+
+func (c *ChatClient) completionRequest(messages []models.ChatMessage, tools []models.ToolDefinition) map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"model":    c.model,
+		"messages": messages,
+	}
+	if len(tools) > 0 {
+		reqBody["tools"] = tools
+	}
+	return reqBody
 }
 
 func (c *ChatClient) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
-	url := "https://api.openai.com/v1/embeddings"
+	url := c.baseURL + "/embeddings"
 
 	reqBody := map[string]interface{}{
 		"input": text,
-		"model": "text-embedding-ada-002",
+		"model": c.embeddingModel,
+	}
+	if c.embeddingDimensions > 0 && strings.HasPrefix(c.embeddingModel, "text-embedding-3") {
+		reqBody["dimensions"] = c.embeddingDimensions
 	}
 
 	reqBytes, err := json.Marshal(reqBody)
@@ -90,8 +140,7 @@ func (c *ChatClient) CreateEmbedding(ctx context.Context, text string) ([]float3
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -122,3 +171,10 @@ func (c *ChatClient) CreateEmbedding(ctx context.Context, text string) ([]float3
 
 	return result.Data[0].Embedding, nil
 }
+
+func (c *ChatClient) setHeaders(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+}
@@ -0,0 +1,136 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"tars-bot/pkg/models"
+)
+
+// CompletionStream requests a server-sent events completion and calls onDelta
+// for every content fragment as it arrives. It returns the assembled reply,
+// including any tool calls, once the stream ends; an error from onDelta aborts
+// the stream.
+func (c *ChatClient) CompletionStream(ctx context.Context, messages []models.ChatMessage, tools []models.ToolDefinition, onDelta func(delta string) error) (models.ChatMessage, error) {
+	url := c.baseURL + "/chat/completions"
+
+	reqBody := c.completionRequest(messages, tools)
+	reqBody["stream"] = true
+
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return models.ChatMessage{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return models.ChatMessage{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+	req.Header.Set("Accept", "text/event-stream")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return models.ChatMessage{}, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return models.ChatMessage{}, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var content strings.Builder
+	var toolCalls []models.ToolCall
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Type     string `json:"type"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		err = json.Unmarshal([]byte(data), &chunk)
+		if err != nil {
+			return models.ChatMessage{}, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+
+		// Tool calls arrive in pieces keyed by index: the first piece carries
+		// the ID and name, later ones append to the JSON arguments.
+		for _, tc := range delta.ToolCalls {
+			for len(toolCalls) <= tc.Index {
+				toolCalls = append(toolCalls, models.ToolCall{Type: "function"})
+			}
+			call := &toolCalls[tc.Index]
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+
+		if delta.Content == "" {
+			continue
+		}
+
+		content.WriteString(delta.Content)
+		if onDelta != nil {
+			err = onDelta(delta.Content)
+			if err != nil {
+				return models.ChatMessage{}, err
+			}
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return models.ChatMessage{}, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if content.Len() == 0 && len(toolCalls) == 0 {
+		return models.ChatMessage{}, fmt.Errorf("no content in stream")
+	}
+
+	return models.ChatMessage{
+		Role:      models.RoleAssistant,
+		Content:   content.String(),
+		ToolCalls: toolCalls,
+	}, nil
+}
@@ -8,8 +8,12 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+
+	"tars-bot/pkg/models"
 )
 
+const DefaultSTTResponseFormat = "verbose_json"
+
 type STTClient struct {
 	apiKey string
 }
@@ -18,33 +22,49 @@ func NewSTTClient(apiKey string) *STTClient {
 	return &STTClient{apiKey: apiKey}
 }
 
-func (s *STTClient) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+// Transcribe uploads audioData for transcription. The extension of filename
+// (.wav, .ogg, ...) tells the API which container the audio is in.
+func (s *STTClient) Transcribe(ctx context.Context, audioData []byte, filename string, opts models.STTOptions) (models.Transcription, error) {
 	url := "https://api.openai.com/v1/audio/transcriptions"
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
 	// Create form file field
-	part, err := writer.CreateFormFile("file", "audio.ogg")
+	part, err := writer.CreateFormFile("file", filename)
 	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %w", err)
+		return models.Transcription{}, fmt.Errorf("failed to create form file: %w", err)
 	}
 	_, err = part.Write(audioData)
 	if err != nil {
-		return "", fmt.Errorf("failed to write audio data: %w", err)
+		return models.Transcription{}, fmt.Errorf("failed to write audio data: %w", err)
 	}
 
-	// Create model field
+	// Create model and option fields
 	_ = writer.WriteField("model", "whisper-1")
+	format := opts.ResponseFormat
+	if format == "" {
+		format = DefaultSTTResponseFormat
+	}
+	_ = writer.WriteField("response_format", format)
+	if format == "verbose_json" {
+		_ = writer.WriteField("timestamp_granularities[]", "segment")
+	}
+	if opts.Language != "" {
+		_ = writer.WriteField("language", opts.Language)
+	}
+	if opts.Prompt != "" {
+		_ = writer.WriteField("prompt", opts.Prompt)
+	}
 
 	err = writer.Close()
 	if err != nil {
-		return "", fmt.Errorf("failed to close writer: %w", err)
+		return models.Transcription{}, fmt.Errorf("failed to close writer: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return models.Transcription{}, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+s.apiKey)
@@ -53,22 +73,20 @@ func (s *STTClient) Transcribe(ctx context.Context, audioData []byte) (string, e
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return models.Transcription{}, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return models.Transcription{}, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var result struct {
-		Text string `json:"text"`
-	}
+	var result models.Transcription
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return models.Transcription{}, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return result.Text, nil
+	return result, nil
 }
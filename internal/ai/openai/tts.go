@@ -7,6 +7,22 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"tars-bot/pkg/models"
+)
+
+const (
+	DefaultTTSModel  = "tts-1"
+	DefaultTTSVoice  = "alloy"
+	DefaultTTSSpeed  = 1.0
+	DefaultTTSFormat = "wav"
+)
+
+var (
+	ttsModels = []string{"tts-1", "tts-1-hd", "gpt-4o-mini-tts"}
+	ttsVoices = []string{"alloy", "ash", "ballad", "coral", "echo", "fable", "nova", "onyx", "sage", "shimmer", "verse"}
+	// ttsFormats are the response formats the voice pipeline can play
+	ttsFormats = []string{"wav", "pcm"}
 )
 
 type TTSClient struct {
@@ -17,13 +33,34 @@ func NewTTSClient(apiKey string) *TTSClient {
 	return &TTSClient{apiKey: apiKey}
 }
 
-func (t *TTSClient) Generate(ctx context.Context, text string) ([]byte, error) {
+// Models lists the speech models the endpoint offers.
+func (t *TTSClient) Models() []string {
+	return ttsModels
+}
+
+// Voices lists the voices the endpoint offers.
+func (t *TTSClient) Voices() []string {
+	return ttsVoices
+}
+
+// Formats lists the playable response formats the endpoint offers.
+func (t *TTSClient) Formats() []string {
+	return ttsFormats
+}
+
+// Generate synthesizes text in the requested format.
+func (t *TTSClient) Generate(ctx context.Context, text string, opts models.TTSOptions) ([]byte, error) {
 	url := "https://api.openai.com/v1/audio/speech"
 
 	reqBody := map[string]interface{}{
-		"model": "tts-1",
-		"input": text,
-		"voice": "alloy",
+		"model":           valueOr(opts.Model, DefaultTTSModel),
+		"input":           text,
+		"voice":           valueOr(opts.Voice, DefaultTTSVoice),
+		"speed":           DefaultTTSSpeed,
+		"response_format": valueOr(opts.Format, DefaultTTSFormat),
+	}
+	if opts.Speed > 0 {
+		reqBody["speed"] = opts.Speed
 	}
 
 	reqBytes, err := json.Marshal(reqBody)
@@ -53,3 +90,10 @@ func (t *TTSClient) Generate(ctx context.Context, text string) ([]byte, error) {
 
 	return io.ReadAll(resp.Body)
 }
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
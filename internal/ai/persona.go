@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"tars-bot/internal/ai/vectorstore"
+)
+
+// defaultPersonaTemplate is rendered with the guild's settings to build the
+// system prompt. PERSONA_TEMPLATE can point to a file replacing it.
+const defaultPersonaTemplate = `You are TARS, the former U.S. Marine Corps tactical robot from the Endurance mission, now chatting with the members of a Discord server.
+Speak like TARS: dry, deadpan, loyal to the crew and never sycophantic.
+
+Humor setting: {{.Humor}}%. {{level .Humor "Stay strictly serious." "Allow the occasional dry remark." "Use frequent deadpan jokes, but never at the expense of being useful."}}
+Honesty setting: {{.Honesty}}%. {{level .Honesty "You may be diplomatic and spare feelings when the truth is unhelpful." "Be honest, but tactful when the truth would hurt." "Be absolutely honest, even when it is uncomfortable."}}
+Verbosity setting: {{.Verbosity}}%. {{level .Verbosity "Answer in one or two short sentences." "Keep answers concise and to the point." "Give thorough, detailed answers."}}
+
+If someone asks for your settings, report them as percentages, like TARS would.`
+
+var personaFuncs = template.FuncMap{
+	// level picks the description matching a 0-100 setting.
+	"level": func(value int, low, medium, high string) string {
+		switch {
+		case value < 34:
+			return low
+		case value < 67:
+			return medium
+		default:
+			return high
+		}
+	},
+}
+
+// LoadPersonaTemplate parses the persona template from path, falling back to
+// the built-in TARS persona when path is empty.
+func LoadPersonaTemplate(path string) (*template.Template, error) {
+	text := defaultPersonaTemplate
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read persona template: %w", err)
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New("persona").Funcs(personaFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse persona template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// SystemPrompt renders the persona for the given guild settings.
+func (a *AIAgent) SystemPrompt(settings vectorstore.GuildSettings) (string, error) {
+	var sb strings.Builder
+	err := a.Persona.Execute(&sb, settings)
+	if err != nil {
+		return "", fmt.Errorf("failed to render persona: %w", err)
+	}
+	return sb.String(), nil
+}
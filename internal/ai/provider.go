@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"tars-bot/internal/ai/openai"
+	"tars-bot/internal/ai/speechhttp"
+	"tars-bot/internal/ai/vectorstore"
+	"tars-bot/internal/config"
+	"tars-bot/pkg/models"
+)
+
+// ChatProvider is a language model backend able to answer a chat history,
+// optionally calling tools, and produce embeddings for the memory store.
+type ChatProvider interface {
+	Completion(ctx context.Context, messages []models.ChatMessage, tools []models.ToolDefinition) (models.ChatMessage, error)
+	CompletionStream(ctx context.Context, messages []models.ChatMessage, tools []models.ToolDefinition, onDelta func(delta string) error) (models.ChatMessage, error)
+	CreateEmbedding(ctx context.Context, text string) ([]float32, error)
+	EmbeddingModel() string
+}
+
+// STT is a speech-to-text backend.
+type STT interface {
+	// Transcribe recognizes the speech in audioData, whose container is
+	// given by the extension of filename.
+	Transcribe(ctx context.Context, audioData []byte, filename string, opts models.STTOptions) (models.Transcription, error)
+}
+
+// TTS is a text-to-speech backend.
+type TTS interface {
+	// Generate synthesizes text as audio in opts.Format, WAV by default.
+	Generate(ctx context.Context, text string, opts models.TTSOptions) ([]byte, error)
+	// Models and Voices list the choices offered, if known.
+	Models() []string
+	Voices() []string
+	// Formats lists the values of opts.Format the backend can produce.
+	Formats() []string
+}
+
+// NewChatProvider builds the chat backend selected by LLM_PROVIDER.
+func NewChatProvider(cfg *config.Config) (ChatProvider, error) {
+	switch cfg.LLMProvider {
+	case "", "openai":
+		return openai.NewCompatibleChatClient(openai.DefaultBaseURL, cfg.OpenAIKey, cfg.LLMModel, cfg.EmbeddingModel, cfg.EmbeddingDimensions), nil
+	case "openai-compatible":
+		if cfg.LLMBaseURL == "" {
+			return nil, fmt.Errorf("LLM_BASE_URL is required for the openai-compatible provider")
+		}
+		return openai.NewCompatibleChatClient(cfg.LLMBaseURL, cfg.LLMAPIKey, cfg.LLMModel, cfg.EmbeddingModel, cfg.EmbeddingDimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLMProvider)
+	}
+}
+
+// NewSTT builds the speech-to-text backend selected by STT_PROVIDER.
+func NewSTT(cfg *config.Config) (STT, error) {
+	switch cfg.STTProvider {
+	case "", "openai":
+		return openai.NewSTTClient(cfg.OpenAIKey), nil
+	case "http":
+		if cfg.STTURL == "" {
+			return nil, fmt.Errorf("STT_URL is required for the http speech-to-text provider")
+		}
+		return speechhttp.NewSTTClient(cfg.STTURL), nil
+	default:
+		return nil, fmt.Errorf("unknown speech-to-text provider %q", cfg.STTProvider)
+	}
+}
+
+// NewTTS builds the text-to-speech backend selected by TTS_PROVIDER.
+func NewTTS(cfg *config.Config) (TTS, error) {
+	switch cfg.TTSProvider {
+	case "", "openai":
+		return openai.NewTTSClient(cfg.OpenAIKey), nil
+	case "http":
+		if cfg.TTSURL == "" {
+			return nil, fmt.Errorf("TTS_URL is required for the http text-to-speech provider")
+		}
+		return speechhttp.NewTTSClient(cfg.TTSURL, cfg.TTSVoices), nil
+	default:
+		return nil, fmt.Errorf("unknown text-to-speech provider %q", cfg.TTSProvider)
+	}
+}
+
+// NewVectorStore opens the memory backend selected by MEMORY_BACKEND.
+// Conversations stored before embedding models were tracked are attributed
+// to embeddingModel.
+func NewVectorStore(cfg *config.Config, embeddingModel string) (vectorstore.Store, error) {
+	switch cfg.MemoryBackend {
+	case "", "postgres":
+		return vectorstore.NewPostgreSQLVectorStore(cfg.PostgresConnString, vectorstore.PostgreSQLOptions{
+			AutoMigrate:         cfg.MigrateOnStart,
+			EmbeddingDimensions: cfg.EmbeddingDimensions,
+			EmbeddingModel:      embeddingModel,
+		})
+	case "sqlite":
+		return vectorstore.NewSQLiteVectorStore(cfg.SQLitePath, embeddingModel)
+	case "memory":
+		return vectorstore.NewInMemoryVectorStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown memory backend %q", cfg.MemoryBackend)
+	}
+}
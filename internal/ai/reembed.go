@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// DefaultReembedBatchSize is the number of conversations re-embedded per batch.
+const DefaultReembedBatchSize = 100
+
+// Reembed rewrites the embedding of every stored conversation not yet
+// embedded with the current embedding model, batchSize rows at a time. Rows
+// are processed in ID order so an interrupted run resumes where it stopped.
+// It returns the number of conversations updated.
+func (a *AIAgent) Reembed(ctx context.Context, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultReembedBatchSize
+	}
+	model := a.Chat.EmbeddingModel()
+
+	err := a.Memory.BeginReembedding(ctx, a.EmbeddingDimensions)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, failed, afterID := 0, 0, 0
+	for {
+		batch, err := a.Memory.PendingReembedding(ctx, model, afterID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, conv := range batch {
+			afterID = conv.ID
+
+			// Memories are retrieved by similarity to the user's message
+			embedding, err := a.embed(ctx, conv.Message)
+			if err != nil {
+				if ctx.Err() != nil {
+					return updated, ctx.Err()
+				}
+				log.Printf("Error re-embedding conversation %d: %v", conv.ID, err)
+				failed++
+				continue
+			}
+
+			err = a.Memory.UpdateEmbedding(ctx, conv.ID, model, embedding)
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+
+		log.Printf("Re-embedded %d conversations with %s", updated, model)
+	}
+
+	if failed > 0 {
+		return updated, fmt.Errorf("%d conversations could not be re-embedded, run the job again to retry", failed)
+	}
+
+	return updated, a.Memory.FinishReembedding(ctx, a.EmbeddingDimensions)
+}
@@ -0,0 +1,176 @@
+// Package speechhttp talks to self-hosted speech servers over a minimal HTTP
+// protocol, so voice can run without a cloud provider.
+//
+// Speech-to-text: POST the STT URL as multipart/form-data with the audio in
+// the "file" field and the optional text fields "language", "prompt" and
+// "response_format" (json or verbose_json). The server answers JSON:
+//
+//	{"text": "...", "language": "en", "duration": 1.5,
+//	 "segments": [{"start": 0, "end": 1.5, "text": "...",
+//	               "avg_logprob": -0.2, "no_speech_prob": 0.01}]}
+//
+// Only "text" is required. This is the shape of the whisper.cpp server's
+// /inference endpoint and of OpenAI's transcription API.
+//
+// Text-to-speech: POST the TTS URL with a JSON body
+//
+//	{"text": "...", "voice": "en_US-lessac-medium", "speed": 1.0, "length_scale": 1.0}
+//
+// where every field but "text" may be omitted and length_scale, piper's name
+// for the inverse of speed, is sent for piper-style servers. The server
+// answers with a 16-bit PCM WAV file.
+package speechhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"tars-bot/pkg/models"
+)
+
+type STTClient struct {
+	url string
+}
+
+func NewSTTClient(url string) *STTClient {
+	return &STTClient{url: url}
+}
+
+// Transcribe uploads audioData for transcription. The extension of filename
+// tells the server which container the audio is in.
+func (s *STTClient) Transcribe(ctx context.Context, audioData []byte, filename string, opts models.STTOptions) (models.Transcription, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return models.Transcription{}, fmt.Errorf("failed to create form file: %w", err)
+	}
+	_, err = part.Write(audioData)
+	if err != nil {
+		return models.Transcription{}, fmt.Errorf("failed to write audio data: %w", err)
+	}
+
+	for name, value := range map[string]string{
+		"language":        opts.Language,
+		"prompt":          opts.Prompt,
+		"response_format": opts.ResponseFormat,
+	} {
+		if value != "" {
+			_ = writer.WriteField(name, value)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return models.Transcription{}, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	resp, err := post(ctx, s.url, writer.FormDataContentType(), body)
+	if err != nil {
+		return models.Transcription{}, err
+	}
+	defer resp.Body.Close()
+
+	var result models.Transcription
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return models.Transcription{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result, nil
+}
+
+type TTSClient struct {
+	url    string
+	voices []string
+}
+
+// NewTTSClient returns a client for the server at url. voices lists what it
+// offers, for autocompletion; the protocol has no way to ask.
+func NewTTSClient(url string, voices []string) *TTSClient {
+	return &TTSClient{url: url, voices: voices}
+}
+
+// Models returns nil; self-hosted servers serve one model per voice.
+func (t *TTSClient) Models() []string {
+	return nil
+}
+
+func (t *TTSClient) Voices() []string {
+	return t.voices
+}
+
+// Formats returns wav, the only format the protocol produces.
+func (t *TTSClient) Formats() []string {
+	return []string{"wav"}
+}
+
+// Generate synthesizes text and returns it as a WAV file. The server always
+// answers WAV, so opts.Format must be empty or wav.
+func (t *TTSClient) Generate(ctx context.Context, text string, opts models.TTSOptions) ([]byte, error) {
+	if opts.Format != "" && opts.Format != "wav" {
+		return nil, fmt.Errorf("speech server only produces wav, not %s", opts.Format)
+	}
+
+	reqBody := map[string]interface{}{
+		"text": text,
+	}
+	if opts.Voice != "" {
+		reqBody["voice"] = opts.Voice
+	}
+	if opts.Speed > 0 {
+		reqBody["speed"] = opts.Speed
+		reqBody["length_scale"] = 1 / opts.Speed
+	}
+
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := post(ctx, t.url, "application/json", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(audio) < 12 || string(audio[0:4]) != "RIFF" || string(audio[8:12]) != "WAVE" {
+		return nil, errors.New("speech server did not answer with a WAV file")
+	}
+
+	return audio, nil
+}
+
+// post sends body to url and returns the response if it succeeded.
+func post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("speech server request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,227 @@
+package speechhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tars-bot/internal/audio"
+	"tars-bot/pkg/models"
+)
+
+func TestTranscribe(t *testing.T) {
+	wav := audio.EncodeWAV([]int16{1, 2, 3, 4}, 16000, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got method %s, want POST", r.Method)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("no file field: %v", err)
+			return
+		}
+		defer file.Close()
+		got, _ := io.ReadAll(file)
+		if !bytes.Equal(got, wav) {
+			t.Error("uploaded audio differs from what was sent")
+		}
+		if header.Filename != "speech.wav" {
+			t.Errorf("got filename %q, want speech.wav", header.Filename)
+		}
+		for field, want := range map[string]string{
+			"language":        "en",
+			"prompt":          "TARS, CASE",
+			"response_format": "verbose_json",
+		} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("got %s %q, want %q", field, got, want)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"text": "hello TARS", "language": "en", "duration": 1.5,
+			"segments": [{"start": 0, "end": 1.5, "text": "hello TARS", "avg_logprob": -0.1, "no_speech_prob": 0.02}]}`)
+	}))
+	defer server.Close()
+
+	result, err := NewSTTClient(server.URL).Transcribe(context.Background(), wav, "speech.wav", models.STTOptions{
+		Language:       "en",
+		Prompt:         "TARS, CASE",
+		ResponseFormat: "verbose_json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Text != "hello TARS" || result.Language != "en" || result.Duration != 1.5 {
+		t.Errorf("unexpected transcription %+v", result)
+	}
+	if len(result.Segments) != 1 || result.Segments[0].NoSpeechProb != 0.02 {
+		t.Errorf("unexpected segments %+v", result.Segments)
+	}
+}
+
+func TestTranscribeOmitsEmptyFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+		for field := range r.MultipartForm.Value {
+			t.Errorf("unexpected field %q", field)
+		}
+		io.WriteString(w, `{"text": "hi"}`)
+	}))
+	defer server.Close()
+
+	result, err := NewSTTClient(server.URL).Transcribe(context.Background(), []byte("audio"), "speech.ogg", models.STTOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Text != "hi" {
+		t.Errorf("got text %q, want hi", result.Text)
+	}
+}
+
+func TestTranscribeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "error status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+			},
+			want: "status 503",
+		},
+		{
+			name: "bad body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "<html>not json</html>")
+			},
+			want: "failed to decode response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			_, err := NewSTTClient(server.URL).Transcribe(context.Background(), []byte("audio"), "speech.wav", models.STTOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	wav := audio.EncodeWAV([]int16{5, 6, 7, 8}, 22050, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("got content type %q, want application/json", ct)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+		want := map[string]interface{}{
+			"text":         "Hello there",
+			"voice":        "en_US-lessac-medium",
+			"speed":        2.0,
+			"length_scale": 0.5,
+		}
+		for key, value := range want {
+			if body[key] != value {
+				t.Errorf("got %s %v, want %v", key, body[key], value)
+			}
+		}
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write(wav)
+	}))
+	defer server.Close()
+
+	got, err := NewTTSClient(server.URL, nil).Generate(context.Background(), "Hello there", models.TTSOptions{
+		Voice: "en_US-lessac-medium",
+		Speed: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, wav) {
+		t.Error("returned audio differs from what the server sent")
+	}
+}
+
+func TestGenerateOmitsDefaults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+		if len(body) != 1 || body["text"] != "Hi" {
+			t.Errorf("got body %v, want only the text", body)
+		}
+		w.Write(audio.EncodeWAV(nil, 22050, 1))
+	}))
+	defer server.Close()
+
+	if _, err := NewTTSClient(server.URL, nil).Generate(context.Background(), "Hi", models.TTSOptions{Format: "wav"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    models.TTSOptions
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "error status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "unknown voice", http.StatusBadRequest)
+			},
+			want: "status 400",
+		},
+		{
+			name: "bad body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, `{"error": "oops"}`)
+			},
+			want: "WAV",
+		},
+		{
+			name: "unsupported format",
+			opts: models.TTSOptions{Format: "pcm"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				t.Error("request sent for a format the server cannot produce")
+			},
+			want: "only produces wav",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			_, err := NewTTSClient(server.URL, nil).Generate(context.Background(), "Hello", tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
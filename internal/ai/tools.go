@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"tars-bot/pkg/models"
+)
+
+// ToolInvocation describes who triggered a tool call and with which arguments.
+type ToolInvocation struct {
+	MessageContext
+	Arguments json.RawMessage
+}
+
+// ToolHandler executes a tool and returns the result handed back to the model.
+type ToolHandler func(ctx context.Context, inv ToolInvocation) (string, error)
+
+// Tool is a Go function the model can call. Parameters is a JSON schema
+// describing the arguments object.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+	Handler     ToolHandler
+}
+
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]Tool),
+	}
+}
+
+// Register adds a tool to the registry. Tool names must be unique.
+func (r *ToolRegistry) Register(tool Tool) error {
+	if tool.Name == "" || tool.Handler == nil {
+		return fmt.Errorf("tool needs a name and a handler")
+	}
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	if !json.Valid(tool.Parameters) {
+		return fmt.Errorf("tool %q has an invalid parameter schema", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %q is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	return nil
+}
+
+// Definitions returns the registered tools in the format advertised to the model.
+func (r *ToolRegistry) Definitions() []models.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]models.ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, models.ToolDefinition{
+			Type: "function",
+			Function: models.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Function.Name < definitions[j].Function.Name
+	})
+	return definitions
+}
+
+// Execute runs the tool requested by call. Failures are reported to the model
+// as the tool result so it can recover instead of aborting the conversation.
+func (r *ToolRegistry) Execute(ctx context.Context, call models.ToolCall, inv ToolInvocation) string {
+	r.mu.RLock()
+	tool, exists := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+	}
+
+	inv.Arguments = json.RawMessage(call.Function.Arguments)
+	if len(inv.Arguments) == 0 {
+		inv.Arguments = json.RawMessage("{}")
+	}
+
+	result, err := tool.Handler(ctx, inv)
+	if err != nil {
+		log.Printf("Error running tool %s: %v", tool.Name, err)
+		return "error: " + err.Error()
+	}
+	return result
+}
+
+// runCompletion asks the model for an answer, executing the tools it requests
+// and feeding their results back until it replies with content or maxSteps
+// tool rounds have been spent.
+func (a *AIAgent) runCompletion(ctx context.Context, messages []models.ChatMessage, inv ToolInvocation, onDelta func(delta string) error) (string, error) {
+	for step := 0; ; step++ {
+		var tools []models.ToolDefinition
+		if step < a.MaxToolSteps {
+			tools = a.Tools.Definitions()
+		}
+
+		var reply models.ChatMessage
+		var err error
+		if onDelta != nil {
+			reply, err = a.Chat.CompletionStream(ctx, messages, tools, onDelta)
+		} else {
+			reply, err = a.Chat.Completion(ctx, messages, tools)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, nil
+		}
+
+		messages = append(messages, reply)
+		for _, call := range reply.ToolCalls {
+			log.Printf("Running tool %s", call.Function.Name)
+			messages = append(messages, models.ChatMessage{
+				Role:       models.RoleTool,
+				ToolCallID: call.ID,
+				Content:    a.Tools.Execute(ctx, call, inv),
+			})
+		}
+	}
+}
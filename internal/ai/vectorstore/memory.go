@@ -0,0 +1,136 @@
+package vectorstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryVectorStore keeps conversations in process and searches them by
+// brute-force cosine similarity. Everything is lost on restart, which makes it
+// suited to tests and small deployments.
+type InMemoryVectorStore struct {
+	mu            sync.RWMutex
+	conversations []Conversation
+	settings      map[string]GuildSettings
+	sessions      map[string]Session
+	nextID        int
+}
+
+func NewInMemoryVectorStore() *InMemoryVectorStore {
+	return &InMemoryVectorStore{
+		settings: make(map[string]GuildSettings),
+		sessions: make(map[string]Session),
+		nextID:   1,
+	}
+}
+
+func (vs *InMemoryVectorStore) StoreConversation(ctx context.Context, conv Conversation) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	now := time.Now()
+	conv.ID = vs.nextID
+	conv.CreatedAt = now
+	conv.UpdatedAt = now
+	conv.Embedding = append([]float32(nil), conv.Embedding...)
+	vs.nextID++
+
+	vs.conversations = append(vs.conversations, conv)
+	return nil
+}
+
+func (vs *InMemoryVectorStore) SearchSimilar(ctx context.Context, q SearchQuery) ([]Conversation, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var candidates []Conversation
+	for _, conv := range vs.conversations {
+		if inScope(conv, q) {
+			candidates = append(candidates, conv)
+		}
+	}
+
+	return rankConversations(candidates, q), nil
+}
+
+func (vs *InMemoryVectorStore) GetGuildSettings(ctx context.Context, guildID string) (GuildSettings, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	settings, exists := vs.settings[guildID]
+	if !exists {
+		return DefaultGuildSettings(guildID), nil
+	}
+	return settings, nil
+}
+
+func (vs *InMemoryVectorStore) SaveGuildSettings(ctx context.Context, settings GuildSettings) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	settings.UpdatedAt = time.Now()
+	vs.settings[settings.GuildID] = settings
+	return nil
+}
+
+func (vs *InMemoryVectorStore) SaveSession(ctx context.Context, session Session) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	session.CreatedAt = time.Now()
+	vs.sessions[session.ChannelID] = session
+	return nil
+}
+
+func (vs *InMemoryVectorStore) GetSession(ctx context.Context, channelID string) (Session, bool, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	session, exists := vs.sessions[channelID]
+	return session, exists, nil
+}
+
+func (vs *InMemoryVectorStore) PendingReembedding(ctx context.Context, model string, afterID, limit int) ([]Conversation, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var pending []Conversation
+	for _, conv := range vs.conversations {
+		if conv.ID > afterID && conv.EmbeddingModel != model {
+			pending = append(pending, conv)
+			if len(pending) == limit {
+				break
+			}
+		}
+	}
+	return pending, nil
+}
+
+func (vs *InMemoryVectorStore) UpdateEmbedding(ctx context.Context, id int, model string, embedding []float32) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	for i := range vs.conversations {
+		if vs.conversations[i].ID == id {
+			vs.conversations[i].Embedding = append([]float32(nil), embedding...)
+			vs.conversations[i].EmbeddingModel = model
+			vs.conversations[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return nil
+}
+
+// BeginReembedding is a no-op: embeddings of any dimension can coexist in memory.
+func (vs *InMemoryVectorStore) BeginReembedding(ctx context.Context, dimensions int) error {
+	return nil
+}
+
+func (vs *InMemoryVectorStore) FinishReembedding(ctx context.Context, dimensions int) error {
+	return nil
+}
+
+func (vs *InMemoryVectorStore) Close() error {
+	return nil
+}
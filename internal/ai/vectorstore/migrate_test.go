@@ -0,0 +1,114 @@
+package vectorstore
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrationsOrdersAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0010_add_index.up.sql":        {Data: []byte("CREATE INDEX i ON t (c);")},
+		"migrations/0010_add_index.down.sql":      {Data: []byte("DROP INDEX i;")},
+		"migrations/0002_add_column.down.sql":     {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"migrations/0001_create_table.up.sql":     {Data: []byte("CREATE TABLE t (v vector({{.EmbeddingDimensions}}));")},
+		"migrations/0002_add_column.up.sql":       {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+		"migrations/0001_create_table.down.sql":   {Data: []byte("DROP TABLE t;")},
+		"migrations/0003_irreversible_fix.up.sql": {Data: []byte("UPDATE t SET c = 0;")},
+	}
+
+	migrations, err := loadMigrations(fsys, MigrationParams{EmbeddingDimensions: 768})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "create_table", Up: "CREATE TABLE t (v vector(768));", Down: "DROP TABLE t;"},
+		{Version: 2, Name: "add_column", Up: "ALTER TABLE t ADD COLUMN c INT;", Down: "ALTER TABLE t DROP COLUMN c;"},
+		{Version: 3, Name: "irreversible_fix", Up: "UPDATE t SET c = 0;"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX i ON t (c);", Down: "DROP INDEX i;"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(migrations), len(want))
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name: "invalid name",
+			files: fstest.MapFS{
+				"migrations/create_table.sql": {Data: []byte("SELECT 1;")},
+			},
+			want: "invalid migration file name",
+		},
+		{
+			name: "missing up script",
+			files: fstest.MapFS{
+				"migrations/0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+			want: "has no up script",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"migrations/0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t ();")},
+				"migrations/0001_create_other.down.sql": {Data: []byte("DROP TABLE o;")},
+			},
+			want: "conflicting names",
+		},
+		{
+			name: "bad template",
+			files: fstest.MapFS{
+				"migrations/0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (v vector({{.Unknown}}));")},
+			},
+			want: "failed to render migration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, MigrationParams{EmbeddingDimensions: 1536})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, MigrationParams{EmbeddingDimensions: 1536, EmbeddingModel: "bob's-embedder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+		if strings.Contains(migration.Up+migration.Down, "{{") {
+			t.Errorf("migration %d_%s was not fully rendered", migration.Version, migration.Name)
+		}
+	}
+	if !strings.Contains(migrations[0].Up, "vector(1536)") {
+		t.Error("embedding dimensions not substituted into the first migration")
+	}
+	if !strings.Contains(migrations[3].Up, "embedding_model = 'bob''s-embedder'") {
+		t.Errorf("embedding model not backfilled as a quoted literal:\n%s", migrations[3].Up)
+	}
+}
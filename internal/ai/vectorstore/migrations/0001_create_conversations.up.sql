@@ -0,0 +1,16 @@
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id VARCHAR(255),
+    user_id VARCHAR(255) NOT NULL,
+    session_id VARCHAR(255),
+    message TEXT NOT NULL,
+    response TEXT NOT NULL,
+    embedding vector({{.EmbeddingDimensions}}),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS conversation_embedding_idx
+ON conversations USING ivfflat (embedding vector_cosine_ops);
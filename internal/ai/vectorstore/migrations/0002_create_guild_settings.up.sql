@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS guild_settings (
+    guild_id VARCHAR(255) PRIMARY KEY,
+    humor INTEGER NOT NULL,
+    honesty INTEGER NOT NULL,
+    verbosity INTEGER NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
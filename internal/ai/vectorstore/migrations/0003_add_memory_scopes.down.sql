@@ -0,0 +1,5 @@
+ALTER TABLE guild_settings DROP COLUMN IF EXISTS memory_scope;
+
+DROP INDEX IF EXISTS conversation_scope_idx;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS channel_id;
@@ -0,0 +1,6 @@
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS channel_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS conversation_scope_idx
+ON conversations (guild_id, channel_id, user_id);
+
+ALTER TABLE guild_settings ADD COLUMN IF NOT EXISTS memory_scope VARCHAR(32) NOT NULL DEFAULT 'user';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS conversation_embedding_model_idx;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS embedding_model;
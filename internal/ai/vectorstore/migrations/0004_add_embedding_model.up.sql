@@ -0,0 +1,6 @@
+ALTER TABLE conversations ADD COLUMN embedding_model VARCHAR(255);
+
+-- Rows written before this migration were embedded with the configured model
+UPDATE conversations SET embedding_model = {{quote .EmbeddingModel}} WHERE embedding_model IS NULL;
+
+CREATE INDEX conversation_embedding_model_idx ON conversations (embedding_model);
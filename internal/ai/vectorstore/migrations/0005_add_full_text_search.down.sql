@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS conversation_search_idx;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS search_vector;
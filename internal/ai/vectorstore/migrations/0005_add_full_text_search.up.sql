@@ -0,0 +1,4 @@
+ALTER TABLE conversations ADD COLUMN search_vector tsvector
+GENERATED ALWAYS AS (to_tsvector('english', message || ' ' || response)) STORED;
+
+CREATE INDEX conversation_search_idx ON conversations USING GIN (search_vector);
@@ -0,0 +1,4 @@
+ALTER TABLE guild_settings DROP COLUMN IF EXISTS tts_format;
+ALTER TABLE guild_settings DROP COLUMN IF EXISTS tts_speed;
+ALTER TABLE guild_settings DROP COLUMN IF EXISTS tts_voice;
+ALTER TABLE guild_settings DROP COLUMN IF EXISTS tts_model;
@@ -0,0 +1,4 @@
+ALTER TABLE guild_settings ADD COLUMN IF NOT EXISTS tts_model VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE guild_settings ADD COLUMN IF NOT EXISTS tts_voice VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE guild_settings ADD COLUMN IF NOT EXISTS tts_speed DOUBLE PRECISION NOT NULL DEFAULT 1;
+ALTER TABLE guild_settings ADD COLUMN IF NOT EXISTS tts_format VARCHAR(16) NOT NULL DEFAULT '';
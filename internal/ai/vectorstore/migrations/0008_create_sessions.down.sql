@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS conversation_session_idx;
+
+DROP TABLE IF EXISTS sessions;
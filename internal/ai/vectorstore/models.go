@@ -8,17 +8,93 @@ import (
 type Conversation struct {
 	ID        int
 	GuildID   string
+	ChannelID string
 	UserID    string
 	SessionID string
 	Message   string
 	Response  string
 	Embedding []float32
+
+	EmbeddingModel string
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Similarity is the cosine similarity to the query embedding and Score
+	// the ranking score; both are only set on search results.
+	Similarity float64
+	Score      float64
+}
+
+// MemoryScope selects which stored conversations are eligible for retrieval.
+type MemoryScope string
+
+const (
+	// ScopeUser limits retrieval to the user's conversations in the current guild.
+	ScopeUser MemoryScope = "user"
+	// ScopeChannel shares memories of everyone in the current channel.
+	ScopeChannel MemoryScope = "channel"
+	// ScopeGuild shares memories of everyone in the current guild.
+	ScopeGuild MemoryScope = "guild"
+	// ScopeGlobal retrieves the user's conversations from every guild.
+	ScopeGlobal MemoryScope = "global"
+)
+
+// SearchMode selects how memories are ranked.
+type SearchMode string
+
+const (
+	// SearchVector ranks by embedding similarity only.
+	SearchVector SearchMode = "vector"
+	// SearchHybrid fuses embedding similarity with full-text relevance.
+	SearchHybrid SearchMode = "hybrid"
+)
+
+// SearchQuery describes a similarity search restricted to Scope.
+type SearchQuery struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	Scope     MemoryScope
+	Embedding []float32
+	Limit     int
+
+	// SessionID, when set, restricts the search to that session whatever
+	// the scope.
+	SessionID string
+
+	// EmbeddingModel restricts the search to rows embedded by that model.
+	EmbeddingModel string
+
+	Mode SearchMode
+	// Text is the query matched lexically in hybrid mode.
+	Text string
+	// MinSimilarity drops results whose cosine similarity is lower, unless
+	// they matched lexically.
+	MinSimilarity float64
 }
 
 type VectorStore interface {
-	StoreConversation(ctx context.Context, guildID, userID, sessionID, message, response string, embedding []float32) error
-	SearchSimilar(ctx context.Context, guildID, userID string, queryEmbedding []float32, limit int) ([]Conversation, error)
+	StoreConversation(ctx context.Context, conv Conversation) error
+	SearchSimilar(ctx context.Context, query SearchQuery) ([]Conversation, error)
 	Close() error
 }
+
+// Reembedder rewrites stored embeddings when the embedding model changes.
+type Reembedder interface {
+	// PendingReembedding lists, by ascending ID after afterID, conversations
+	// not yet embedded with model.
+	PendingReembedding(ctx context.Context, model string, afterID, limit int) ([]Conversation, error)
+	UpdateEmbedding(ctx context.Context, id int, model string, embedding []float32) error
+	BeginReembedding(ctx context.Context, dimensions int) error
+	FinishReembedding(ctx context.Context, dimensions int) error
+}
+
+// Store is the persistence backend of the agent: conversation memory plus
+// per-guild settings and conversation sessions.
+type Store interface {
+	VectorStore
+	SettingsStore
+	SessionStore
+	Reembedder
+}
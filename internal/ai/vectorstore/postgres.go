@@ -2,8 +2,14 @@ package vectorstore
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -11,7 +17,41 @@ type PostgreSQLVectorStore struct {
 	pool *pgxpool.Pool
 }
 
-func NewPostgreSQLVectorStore(connString string) (*PostgreSQLVectorStore, error) {
+// PostgreSQLOptions configures NewPostgreSQLVectorStore.
+type PostgreSQLOptions struct {
+	// AutoMigrate applies pending schema migrations on connect.
+	AutoMigrate bool
+	// EmbeddingDimensions sizes the embedding column of new databases.
+	EmbeddingDimensions int
+	// EmbeddingModel is recorded for rows stored before embedding models
+	// were tracked.
+	EmbeddingModel string
+}
+
+// NewPostgreSQLVectorStore connects to PostgreSQL and, when requested,
+// applies pending schema migrations before returning.
+func NewPostgreSQLVectorStore(connString string, opts PostgreSQLOptions) (*PostgreSQLVectorStore, error) {
+	pool, err := NewPostgreSQLPool(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.AutoMigrate {
+		err = migrateUp(pool, MigrationParams{
+			EmbeddingDimensions: opts.EmbeddingDimensions,
+			EmbeddingModel:      opts.EmbeddingModel,
+		})
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	return &PostgreSQLVectorStore{pool: pool}, nil
+}
+
+// NewPostgreSQLPool opens and checks a connection pool to connString.
+func NewPostgreSQLPool(connString string) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse connection string: %w", err)
@@ -25,67 +65,37 @@ func NewPostgreSQLVectorStore(connString string) (*PostgreSQLVectorStore, error)
 	// Test the connection
 	err = pool.Ping(context.Background())
 	if err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Initialize tables if they don't exist
-	err = initializeDatabase(pool)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
-	}
-
-	return &PostgreSQLVectorStore{pool: pool}, nil
+	return pool, nil
 }
 
-func initializeDatabase(pool *pgxpool.Pool) error {
-	// Enable pgvector extension if not already enabled
-	_, err := pool.Exec(context.Background(), "CREATE EXTENSION IF NOT EXISTS vector")
-	if err != nil {
-		return fmt.Errorf("failed to enable vector extension: %w", err)
-	}
-
-	// Create conversations table
-	_, err = pool.Exec(context.Background(), `
-        CREATE TABLE IF NOT EXISTS conversations (
-            id BIGSERIAL PRIMARY KEY,
-            guild_id VARCHAR(255),
-            user_id VARCHAR(255) NOT NULL,
-            session_id VARCHAR(255),
-            message TEXT NOT NULL,
-            response TEXT NOT NULL,
-            embedding vector(1536),
-            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-            updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-        )
-    `)
+func migrateUp(pool *pgxpool.Pool, params MigrationParams) error {
+	migrator, err := NewMigrator(pool, params)
 	if err != nil {
-		return fmt.Errorf("failed to create conversations table: %w", err)
+		return err
 	}
 
-	// Create index for vector search
-	_, err = pool.Exec(context.Background(), `
-        CREATE INDEX IF NOT EXISTS conversation_embedding_idx
-        ON conversations USING ivfflat (embedding vector_cosine_ops)
-    `)
-	if err != nil {
-		return fmt.Errorf("failed to create vector index: %w", err)
+	applied, err := migrator.Up(context.Background())
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
 	}
-
-	return nil
+	return err
 }
 
-func (vs *PostgreSQLVectorStore) StoreConversation(
-	ctx context.Context,
-	guildID, userID, sessionID, message, response string,
-	embedding []float32,
-) error {
+func (vs *PostgreSQLVectorStore) StoreConversation(ctx context.Context, conv Conversation) error {
 	query := `
         INSERT INTO conversations
-        (guild_id, user_id, session_id, message, response, embedding)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        (guild_id, channel_id, user_id, session_id, message, response, embedding, embedding_model)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
     `
 
-	_, err := vs.pool.Exec(ctx, query, guildID, userID, sessionID, message, response, embedding)
+	_, err := vs.pool.Exec(ctx, query,
+		conv.GuildID, conv.ChannelID, conv.UserID, conv.SessionID,
+		conv.Message, conv.Response, pgvector(conv.Embedding), conv.EmbeddingModel,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to store conversation: %w", err)
 	}
@@ -93,21 +103,80 @@ func (vs *PostgreSQLVectorStore) StoreConversation(
 	return nil
 }
 
-func (vs *PostgreSQLVectorStore) SearchSimilar(
-	ctx context.Context,
-	guildID, userID string,
-	embedding []float32,
-	limit int,
-) ([]Conversation, error) {
-	query := `
-        SELECT id, guild_id, user_id, session_id, message, response, embedding, created_at, updated_at
+// SearchSimilar returns the conversations closest to q.Embedding. In hybrid
+// mode the vector ranking is fused with a full-text ranking of q.Text using
+// reciprocal-rank fusion, so exact names and identifiers are found even when
+// their embeddings are not close. Vector-only matches below q.MinSimilarity
+// are dropped.
+func (vs *PostgreSQLVectorStore) SearchSimilar(ctx context.Context, q SearchQuery) ([]Conversation, error) {
+	filter, args := scopeFilter(q)
+	if q.EmbeddingModel != "" {
+		// Only compare embeddings produced by the same model
+		args = append(args, q.EmbeddingModel)
+		filter += fmt.Sprintf(" AND embedding_model = $%d", len(args))
+	}
+
+	// LIMIT NULL returns every row, as a zero limit does for the other stores
+	var limit interface{}
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+
+	var query string
+	if q.Mode == SearchHybrid && q.Text != "" {
+		args = append(args, pgvector(q.Embedding), q.Text, candidatePoolSize(q.Limit), q.MinSimilarity, limit)
+		n := len(args)
+		query = fmt.Sprintf(`
+        WITH scoped AS (
+            SELECT id, embedding, search_vector
+            FROM conversations
+            WHERE %[1]s
+        ),
+        vector_hits AS (
+            SELECT id, (1 - (embedding <=> $%[2]d))::float8 AS similarity,
+                   ROW_NUMBER() OVER (ORDER BY embedding <=> $%[2]d) AS rank
+            FROM scoped
+            ORDER BY embedding <=> $%[2]d
+            LIMIT $%[4]d
+        ),
+        search AS (
+            -- Match any query term rather than all of them
+            SELECT CAST(replace(plainto_tsquery('english', $%[3]d)::text, ' & ', ' | ') AS tsquery) AS query
+        ),
+        lexical_hits AS (
+            SELECT id, ROW_NUMBER() OVER (ORDER BY ts_rank_cd(search_vector, query) DESC) AS rank
+            FROM scoped, search
+            WHERE search_vector @@ query
+            ORDER BY ts_rank_cd(search_vector, query) DESC
+            LIMIT $%[4]d
+        )
+        SELECT c.id, COALESCE(c.guild_id, ''), COALESCE(c.channel_id, ''), c.user_id, COALESCE(c.session_id, ''),
+               c.message, c.response, c.embedding, COALESCE(c.embedding_model, ''), c.created_at, c.updated_at,
+               COALESCE(v.similarity, (1 - (c.embedding <=> $%[2]d))::float8) AS similarity,
+               (COALESCE(1.0 / (%[7]d + v.rank), 0) + COALESCE(1.0 / (%[7]d + l.rank), 0))::float8 AS score
+        FROM vector_hits v
+        FULL OUTER JOIN lexical_hits l ON l.id = v.id
+        JOIN conversations c ON c.id = COALESCE(v.id, l.id)
+        WHERE l.id IS NOT NULL OR v.similarity >= $%[5]d
+        ORDER BY score DESC
+        LIMIT $%[6]d
+    `, filter, n-4, n-3, n-2, n-1, n, rrfK)
+	} else {
+		args = append(args, pgvector(q.Embedding), q.MinSimilarity, limit)
+		n := len(args)
+		query = fmt.Sprintf(`
+        SELECT id, COALESCE(guild_id, ''), COALESCE(channel_id, ''), user_id, COALESCE(session_id, ''),
+               message, response, embedding, COALESCE(embedding_model, ''), created_at, updated_at,
+               (1 - (embedding <=> $%[2]d))::float8 AS similarity,
+               (1 - (embedding <=> $%[2]d))::float8 AS score
         FROM conversations
-        WHERE user_id = $1
-        ORDER BY embedding <=> $2
-        LIMIT $3
-    `
+        WHERE %[1]s AND 1 - (embedding <=> $%[2]d) >= $%[3]d
+        ORDER BY embedding <=> $%[2]d
+        LIMIT $%[4]d
+    `, filter, n-2, n-1, n)
+	}
 
-	rows, err := vs.pool.Query(ctx, query, userID, embedding, limit)
+	rows, err := vs.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search similar conversations: %w", err)
 	}
@@ -119,13 +188,17 @@ func (vs *PostgreSQLVectorStore) SearchSimilar(
 		err := rows.Scan(
 			&conv.ID,
 			&conv.GuildID,
+			&conv.ChannelID,
 			&conv.UserID,
 			&conv.SessionID,
 			&conv.Message,
 			&conv.Response,
-			&conv.Embedding,
+			(*pgvector)(&conv.Embedding),
+			&conv.EmbeddingModel,
 			&conv.CreatedAt,
 			&conv.UpdatedAt,
+			&conv.Similarity,
+			&conv.Score,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan conversation: %w", err)
@@ -133,10 +206,298 @@ func (vs *PostgreSQLVectorStore) SearchSimilar(
 		conversations = append(conversations, conv)
 	}
 
-	return conversations, nil
+	return conversations, rows.Err()
+}
+
+// scopeFilter returns the WHERE clause restricting a search to its scope,
+// along with its positional arguments.
+func scopeFilter(q SearchQuery) (string, []interface{}) {
+	if q.SessionID != "" {
+		return "session_id = $1", []interface{}{q.SessionID}
+	}
+
+	switch q.Scope {
+	case ScopeChannel:
+		return "guild_id = $1 AND channel_id = $2", []interface{}{q.GuildID, q.ChannelID}
+	case ScopeGuild:
+		return "guild_id = $1", []interface{}{q.GuildID}
+	case ScopeGlobal:
+		return "user_id = $1", []interface{}{q.UserID}
+	default:
+		return "guild_id = $1 AND user_id = $2", []interface{}{q.GuildID, q.UserID}
+	}
+}
+
+func (vs *PostgreSQLVectorStore) GetGuildSettings(ctx context.Context, guildID string) (GuildSettings, error) {
+	query := `
+        SELECT guild_id, humor, honesty, verbosity, memory_scope, always_listen,
+            tts_model, tts_voice, tts_speed, tts_format, updated_at
+        FROM guild_settings
+        WHERE guild_id = $1
+    `
+
+	settings := DefaultGuildSettings(guildID)
+	err := vs.pool.QueryRow(ctx, query, guildID).Scan(
+		&settings.GuildID,
+		&settings.Humor,
+		&settings.Honesty,
+		&settings.Verbosity,
+		&settings.MemoryScope,
+		&settings.AlwaysListen,
+		&settings.TTSModel,
+		&settings.TTSVoice,
+		&settings.TTSSpeed,
+		&settings.TTSFormat,
+		&settings.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultGuildSettings(guildID), nil
+	}
+	if err != nil {
+		return GuildSettings{}, fmt.Errorf("failed to load guild settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (vs *PostgreSQLVectorStore) SaveGuildSettings(ctx context.Context, settings GuildSettings) error {
+	query := `
+        INSERT INTO guild_settings (guild_id, humor, honesty, verbosity, memory_scope, always_listen,
+            tts_model, tts_voice, tts_speed, tts_format, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+        ON CONFLICT (guild_id) DO UPDATE SET
+            humor = EXCLUDED.humor,
+            honesty = EXCLUDED.honesty,
+            verbosity = EXCLUDED.verbosity,
+            memory_scope = EXCLUDED.memory_scope,
+            always_listen = EXCLUDED.always_listen,
+            tts_model = EXCLUDED.tts_model,
+            tts_voice = EXCLUDED.tts_voice,
+            tts_speed = EXCLUDED.tts_speed,
+            tts_format = EXCLUDED.tts_format,
+            updated_at = NOW()
+    `
+
+	_, err := vs.pool.Exec(ctx, query,
+		settings.GuildID, settings.Humor, settings.Honesty, settings.Verbosity, settings.MemoryScope,
+		settings.AlwaysListen, settings.TTSModel, settings.TTSVoice, settings.TTSSpeed, settings.TTSFormat,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save guild settings: %w", err)
+	}
+
+	return nil
+}
+
+func (vs *PostgreSQLVectorStore) PendingReembedding(ctx context.Context, model string, afterID, limit int) ([]Conversation, error) {
+	query := `
+        SELECT id, message, response
+        FROM conversations
+        WHERE id > $1 AND embedding_model IS DISTINCT FROM $2
+        ORDER BY id
+        LIMIT $3
+    `
+
+	rows, err := vs.pool.Query(ctx, query, afterID, model, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations to re-embed: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []Conversation
+	for rows.Next() {
+		var conv Conversation
+		err := rows.Scan(&conv.ID, &conv.Message, &conv.Response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	return conversations, rows.Err()
+}
+
+func (vs *PostgreSQLVectorStore) UpdateEmbedding(ctx context.Context, id int, model string, embedding []float32) error {
+	query := `
+        UPDATE conversations
+        SET embedding = $2, embedding_model = $3, updated_at = NOW()
+        WHERE id = $1
+    `
+
+	_, err := vs.pool.Exec(ctx, query, id, pgvector(embedding), model)
+	if err != nil {
+		return fmt.Errorf("failed to update embedding: %w", err)
+	}
+
+	return nil
+}
+
+// BeginReembedding relaxes the embedding column to an unsized vector when its
+// dimension differs from dimensions, so old and new embeddings can coexist
+// while rows are rewritten. The ivfflat index only supports sized vectors and
+// is dropped until FinishReembedding.
+func (vs *PostgreSQLVectorStore) BeginReembedding(ctx context.Context, dimensions int) error {
+	current, err := vs.embeddingDimensions(ctx)
+	if err != nil {
+		return err
+	}
+	if current == dimensions || current < 0 {
+		return nil
+	}
+
+	_, err = vs.pool.Exec(ctx, `
+        DROP INDEX IF EXISTS conversation_embedding_idx;
+        ALTER TABLE conversations ALTER COLUMN embedding TYPE vector;
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to resize embedding column: %w", err)
+	}
+
+	return nil
+}
+
+// FinishReembedding restores the sized embedding column and its index once
+// every row has been rewritten.
+func (vs *PostgreSQLVectorStore) FinishReembedding(ctx context.Context, dimensions int) error {
+	current, err := vs.embeddingDimensions(ctx)
+	if err != nil {
+		return err
+	}
+	if current == dimensions {
+		return nil
+	}
+
+	_, err = vs.pool.Exec(ctx, fmt.Sprintf(`
+        ALTER TABLE conversations ALTER COLUMN embedding TYPE vector(%d);
+        CREATE INDEX IF NOT EXISTS conversation_embedding_idx
+        ON conversations USING ivfflat (embedding vector_cosine_ops);
+    `, dimensions))
+	if err != nil {
+		return fmt.Errorf("failed to restore embedding column: %w", err)
+	}
+
+	return nil
+}
+
+// embeddingDimensions returns the declared dimension of the embedding
+// column, or -1 when it is unsized.
+func (vs *PostgreSQLVectorStore) embeddingDimensions(ctx context.Context) (int, error) {
+	query := `
+        SELECT atttypmod
+        FROM pg_attribute
+        WHERE attrelid = 'conversations'::regclass AND attname = 'embedding'
+    `
+
+	var dimensions int
+	err := vs.pool.QueryRow(ctx, query).Scan(&dimensions)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read embedding dimensions: %w", err)
+	}
+
+	return dimensions, nil
+}
+
+func (vs *PostgreSQLVectorStore) SaveSession(ctx context.Context, session Session) error {
+	query := `
+        INSERT INTO sessions (channel_id, session_id, guild_id, user_id, created_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        ON CONFLICT (channel_id) DO UPDATE SET
+            session_id = EXCLUDED.session_id,
+            guild_id = EXCLUDED.guild_id,
+            user_id = EXCLUDED.user_id,
+            created_at = NOW()
+    `
+
+	_, err := vs.pool.Exec(ctx, query, session.ChannelID, session.SessionID, session.GuildID, session.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return nil
+}
+
+func (vs *PostgreSQLVectorStore) GetSession(ctx context.Context, channelID string) (Session, bool, error) {
+	query := `
+        SELECT channel_id, session_id, guild_id, user_id, created_at
+        FROM sessions
+        WHERE channel_id = $1
+    `
+
+	var session Session
+	err := vs.pool.QueryRow(ctx, query, channelID).Scan(
+		&session.ChannelID,
+		&session.SessionID,
+		&session.GuildID,
+		&session.UserID,
+		&session.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	return session, true, nil
 }
 
 func (vs *PostgreSQLVectorStore) Close() error {
 	vs.pool.Close()
 	return nil
 }
+
+// pgvector is an embedding in the text form of the pgvector type, "[1,2,3]",
+// which pgx has no codec for.
+type pgvector []float32
+
+// Value implements driver.Valuer.
+func (v pgvector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, value := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(value), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (v *pgvector) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into an embedding", src)
+	}
+
+	text = strings.TrimSpace(text)
+	if len(text) < 2 || text[0] != '[' || text[len(text)-1] != ']' {
+		return fmt.Errorf("invalid vector %q", text)
+	}
+
+	embedding := []float32{}
+	if body := text[1 : len(text)-1]; body != "" {
+		for _, field := range strings.Split(body, ",") {
+			value, err := strconv.ParseFloat(strings.TrimSpace(field), 32)
+			if err != nil {
+				return fmt.Errorf("invalid vector %q: %w", text, err)
+			}
+			embedding = append(embedding, float32(value))
+		}
+	}
+	*v = embedding
+	return nil
+}
@@ -0,0 +1,25 @@
+package vectorstore
+
+import (
+	"context"
+	"time"
+)
+
+// Session binds a Discord channel, usually a thread started with /talk, to
+// a conversation whose turns are stored and recalled together.
+type Session struct {
+	ChannelID string
+	SessionID string
+	GuildID   string
+	// UserID is the user who started the session
+	UserID string
+
+	CreatedAt time.Time
+}
+
+type SessionStore interface {
+	SaveSession(ctx context.Context, session Session) error
+	// GetSession returns the session bound to channelID, reporting false if
+	// there is none.
+	GetSession(ctx context.Context, channelID string) (Session, bool, error)
+}
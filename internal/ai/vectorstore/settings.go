@@ -0,0 +1,58 @@
+package vectorstore
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DefaultHumor     = 75
+	DefaultHonesty   = 90
+	DefaultVerbosity = 50
+
+	DefaultMemoryScope = ScopeUser
+
+	DefaultTTSSpeed = 1.0
+)
+
+// GuildSettings holds the per-guild configuration of the bot.
+type GuildSettings struct {
+	GuildID   string
+	Humor     int
+	Honesty   int
+	Verbosity int
+
+	MemoryScope MemoryScope
+
+	// AlwaysListen makes TARS answer every voice utterance instead of only
+	// those addressed to it by wake word.
+	AlwaysListen bool
+
+	// How TARS sounds in voice channels. Empty values leave the choice to
+	// the speech backend.
+	TTSModel  string
+	TTSVoice  string
+	TTSSpeed  float64
+	TTSFormat string
+
+	UpdatedAt time.Time
+}
+
+type SettingsStore interface {
+	GetGuildSettings(ctx context.Context, guildID string) (GuildSettings, error)
+	SaveGuildSettings(ctx context.Context, settings GuildSettings) error
+}
+
+// DefaultGuildSettings returns the settings used for guilds that never ran /settings.
+func DefaultGuildSettings(guildID string) GuildSettings {
+	return GuildSettings{
+		GuildID:   guildID,
+		Humor:     DefaultHumor,
+		Honesty:   DefaultHonesty,
+		Verbosity: DefaultVerbosity,
+
+		MemoryScope: DefaultMemoryScope,
+
+		TTSSpeed: DefaultTTSSpeed,
+	}
+}
@@ -0,0 +1,194 @@
+package vectorstore
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// rrfK is the reciprocal-rank fusion constant: a result ranked r in a list
+// contributes 1/(rrfK+r) to its fused score.
+const rrfK = 60
+
+// stopWords are skipped when matching query terms lexically.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "did": true, "do": true, "for": true, "from": true, "how": true,
+	"i": true, "in": true, "is": true, "it": true, "me": true, "my": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"what": true, "when": true, "where": true, "who": true, "why": true, "with": true,
+	"you": true, "your": true,
+}
+
+// candidatePoolSize is the number of results each ranking contributes to the
+// fusion before the final limit is applied.
+func candidatePoolSize(limit int) int {
+	if limit*4 > 20 {
+		return limit * 4
+	}
+	return 20
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 when
+// their dimensions differ or either is a zero vector.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// inScope reports whether conv may be retrieved by q, mirroring the
+// PostgreSQL scope filter.
+func inScope(conv Conversation, q SearchQuery) bool {
+	if q.EmbeddingModel != "" && conv.EmbeddingModel != q.EmbeddingModel {
+		return false
+	}
+	if q.SessionID != "" {
+		return conv.SessionID == q.SessionID
+	}
+
+	switch q.Scope {
+	case ScopeChannel:
+		return conv.GuildID == q.GuildID && conv.ChannelID == q.ChannelID
+	case ScopeGuild:
+		return conv.GuildID == q.GuildID
+	case ScopeGlobal:
+		return conv.UserID == q.UserID
+	default:
+		return conv.GuildID == q.GuildID && conv.UserID == q.UserID
+	}
+}
+
+// terms splits text into lowercase words, keeping identifiers such as
+// "abc-123" or "foo_bar" whole and dropping stop words.
+func terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	})
+
+	var result []string
+	for _, word := range words {
+		word = strings.Trim(word, "-_")
+		if word != "" && !stopWords[word] {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// lexicalScore counts how many distinct query terms appear in conv.
+func lexicalScore(conv Conversation, queryTerms []string) int {
+	present := make(map[string]bool)
+	for _, term := range terms(conv.Message + " " + conv.Response) {
+		present[term] = true
+	}
+
+	score := 0
+	for _, term := range queryTerms {
+		if present[term] {
+			score++
+		}
+	}
+	return score
+}
+
+// rankConversations ranks in-scope candidates the way the PostgreSQL store
+// does: by cosine similarity, fused with a lexical ranking in hybrid mode.
+func rankConversations(candidates []Conversation, q SearchQuery) []Conversation {
+	pool := candidatePoolSize(q.Limit)
+	for i := range candidates {
+		candidates[i].Similarity = cosineSimilarity(candidates[i].Embedding, q.Embedding)
+	}
+
+	// Vector ranking
+	vectorRank := make([]int, len(candidates))
+	for i := range vectorRank {
+		vectorRank[i] = i
+	}
+	sort.SliceStable(vectorRank, func(i, j int) bool {
+		return candidates[vectorRank[i]].Similarity > candidates[vectorRank[j]].Similarity
+	})
+
+	if q.Mode != SearchHybrid || q.Text == "" {
+		var ranked []Conversation
+		for _, index := range vectorRank {
+			conv := candidates[index]
+			if conv.Similarity < q.MinSimilarity {
+				break
+			}
+			conv.Score = conv.Similarity
+			ranked = append(ranked, conv)
+			if q.Limit > 0 && len(ranked) == q.Limit {
+				break
+			}
+		}
+		return ranked
+	}
+
+	// Lexical ranking
+	queryTerms := terms(q.Text)
+	lexical := make([]int, len(candidates))
+	var lexicalRank []int
+	for i := range candidates {
+		lexical[i] = lexicalScore(candidates[i], queryTerms)
+		if lexical[i] > 0 {
+			lexicalRank = append(lexicalRank, i)
+		}
+	}
+	sort.SliceStable(lexicalRank, func(i, j int) bool {
+		return lexical[lexicalRank[i]] > lexical[lexicalRank[j]]
+	})
+
+	// Reciprocal-rank fusion over the top of both rankings
+	scores := make(map[int]float64)
+	lexicalHit := make(map[int]bool)
+	for rank, index := range vectorRank {
+		if rank == pool {
+			break
+		}
+		scores[index] += 1.0 / float64(rrfK+rank+1)
+	}
+	for rank, index := range lexicalRank {
+		if rank == pool {
+			break
+		}
+		scores[index] += 1.0 / float64(rrfK+rank+1)
+		lexicalHit[index] = true
+	}
+
+	var fused []int
+	for index := range scores {
+		if lexicalHit[index] || candidates[index].Similarity >= q.MinSimilarity {
+			fused = append(fused, index)
+		}
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if scores[fused[i]] != scores[fused[j]] {
+			return scores[fused[i]] > scores[fused[j]]
+		}
+		return fused[i] < fused[j]
+	})
+
+	if q.Limit > 0 && len(fused) > q.Limit {
+		fused = fused[:q.Limit]
+	}
+
+	ranked := make([]Conversation, len(fused))
+	for i, index := range fused {
+		ranked[i] = candidates[index]
+		ranked[i].Score = scores[index]
+	}
+	return ranked
+}
@@ -0,0 +1,128 @@
+package vectorstore
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"identical", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"scaled", []float32{1, 1}, []float32{5, 5}, 1},
+		{"dimension mismatch", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+		{"empty", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := terms("What is the status of ticket ABC-123 and foo_bar?")
+	want := []string{"status", "ticket", "abc-123", "foo_bar"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRankConversationsHybrid(t *testing.T) {
+	candidates := []Conversation{
+		// Semantically close but shares no words with the query
+		{ID: 1, Message: "tell me about the weather", Embedding: []float32{1, 0}},
+		// Exact identifier match with an unrelated embedding
+		{ID: 2, Message: "ABC-123 is blocked", Embedding: []float32{0, 1}},
+		// Both close and matching
+		{ID: 3, Message: "ticket ABC-123 weather outage", Embedding: []float32{0.9, 0.1}},
+		// Neither
+		{ID: 4, Message: "pizza toppings", Embedding: []float32{-1, 0}},
+	}
+
+	ranked := rankConversations(candidates, SearchQuery{
+		Embedding:     []float32{1, 0},
+		Mode:          SearchHybrid,
+		Text:          "ABC-123 ticket",
+		MinSimilarity: 0.3,
+	})
+
+	var got []int
+	for _, conv := range ranked {
+		got = append(got, conv.ID)
+	}
+	// 3 ranks second by vector and first lexically. 2 is kept despite its
+	// low similarity because it matched lexically, and its two mid ranks
+	// outweigh the single top rank of 1. 4 is dropped.
+	if want := []int{3, 2, 1}; !slices.Equal(got, want) {
+		t.Fatalf("got order %v, want %v", got, want)
+	}
+
+	// Fused scores are sums of 1/(rrfK+rank)
+	want3 := 1.0/(rrfK+2) + 1.0/(rrfK+1)
+	if math.Abs(ranked[0].Score-want3) > 1e-12 {
+		t.Errorf("got score %v for the top result, want %v", ranked[0].Score, want3)
+	}
+	want1 := 1.0 / (rrfK + 1)
+	if math.Abs(ranked[2].Score-want1) > 1e-12 {
+		t.Errorf("got score %v for the vector-only result, want %v", ranked[2].Score, want1)
+	}
+}
+
+func TestRankConversationsVectorMode(t *testing.T) {
+	candidates := []Conversation{
+		{ID: 1, Message: "ticket ABC-123", Embedding: []float32{0, 1}},
+		{ID: 2, Message: "weather", Embedding: []float32{1, 0}},
+		{ID: 3, Message: "forecast", Embedding: []float32{0.8, 0.6}},
+	}
+
+	ranked := rankConversations(candidates, SearchQuery{
+		Embedding:     []float32{1, 0},
+		Mode:          SearchVector,
+		Text:          "ABC-123 ticket",
+		MinSimilarity: 0.5,
+		Limit:         5,
+	})
+
+	var got []int
+	for _, conv := range ranked {
+		got = append(got, conv.ID)
+	}
+	// Lexical matches count for nothing outside hybrid mode
+	if want := []int{2, 3}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRankConversationsLimit(t *testing.T) {
+	var candidates []Conversation
+	for i := 0; i < 10; i++ {
+		candidates = append(candidates, Conversation{ID: i, Message: "ticket", Embedding: []float32{1, float32(i)}})
+	}
+
+	ranked := rankConversations(candidates, SearchQuery{
+		Embedding: []float32{1, 0},
+		Mode:      SearchHybrid,
+		Text:      "ticket",
+		Limit:     3,
+	})
+	if len(ranked) != 3 {
+		t.Fatalf("got %d results, want 3", len(ranked))
+	}
+	// Equal lexical scores keep insertion order, so the fused order follows
+	// the vector ranking
+	for i, conv := range ranked {
+		if conv.ID != i {
+			t.Errorf("result %d has ID %d, want %d", i, conv.ID, i)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteVectorStore persists conversations in a SQLite file. Embeddings are
+// stored as little-endian float32 blobs and searched by brute-force cosine
+// similarity over the conversations in scope.
+type SQLiteVectorStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteVectorStore opens or creates the database at path. Rows stored
+// before embedding models were tracked are recorded as embedded by
+// embeddingModel.
+func NewSQLiteVectorStore(path, embeddingModel string) (*SQLiteVectorStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	err = initializeSQLiteDatabase(db, embeddingModel)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return &SQLiteVectorStore{db: db}, nil
+}
+
+func initializeSQLiteDatabase(db *sql.DB, embeddingModel string) error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS conversations (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            guild_id TEXT NOT NULL DEFAULT '',
+            channel_id TEXT NOT NULL DEFAULT '',
+            user_id TEXT NOT NULL,
+            session_id TEXT NOT NULL DEFAULT '',
+            message TEXT NOT NULL,
+            response TEXT NOT NULL,
+            embedding BLOB,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create conversations table: %w", err)
+	}
+
+	added, err := addSQLiteColumn(db, "conversations", "embedding_model", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	if added {
+		// Existing rows were embedded with the configured model
+		_, err = db.Exec(`UPDATE conversations SET embedding_model = ?`, embeddingModel)
+		if err != nil {
+			return fmt.Errorf("failed to backfill embedding models: %w", err)
+		}
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS conversation_scope_idx
+        ON conversations (guild_id, channel_id, user_id)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create scope index: %w", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS guild_settings (
+            guild_id TEXT PRIMARY KEY,
+            humor INTEGER NOT NULL,
+            honesty INTEGER NOT NULL,
+            verbosity INTEGER NOT NULL,
+            memory_scope TEXT NOT NULL DEFAULT 'user',
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create guild settings table: %w", err)
+	}
+
+	_, err = addSQLiteColumn(db, "guild_settings", "always_listen", "BOOLEAN NOT NULL DEFAULT FALSE")
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []struct{ name, definition string }{
+		{"tts_model", "TEXT NOT NULL DEFAULT ''"},
+		{"tts_voice", "TEXT NOT NULL DEFAULT ''"},
+		{"tts_speed", "REAL NOT NULL DEFAULT 1"},
+		{"tts_format", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		_, err = addSQLiteColumn(db, "guild_settings", column.name, column.definition)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS sessions (
+            channel_id TEXT PRIMARY KEY,
+            session_id TEXT NOT NULL,
+            guild_id TEXT NOT NULL DEFAULT '',
+            user_id TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create sessions table: %w", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS conversation_session_idx
+        ON conversations (session_id)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create session index: %w", err)
+	}
+
+	return nil
+}
+
+// addSQLiteColumn adds column to table unless it already exists, SQLite
+// having no ADD COLUMN IF NOT EXISTS, and reports whether it did.
+func addSQLiteColumn(db *sql.DB, table, column, definition string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return false, fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return true, nil
+}
+
+func (vs *SQLiteVectorStore) StoreConversation(ctx context.Context, conv Conversation) error {
+	query := `
+        INSERT INTO conversations
+        (guild_id, channel_id, user_id, session_id, message, response, embedding, embedding_model, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+
+	now := time.Now().UTC()
+	_, err := vs.db.ExecContext(ctx, query,
+		conv.GuildID, conv.ChannelID, conv.UserID, conv.SessionID,
+		conv.Message, conv.Response, encodeEmbedding(conv.Embedding), conv.EmbeddingModel, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store conversation: %w", err)
+	}
+
+	return nil
+}
+
+func (vs *SQLiteVectorStore) SearchSimilar(ctx context.Context, q SearchQuery) ([]Conversation, error) {
+	var filter string
+	var args []interface{}
+	switch {
+	case q.SessionID != "":
+		filter, args = "session_id = ?", []interface{}{q.SessionID}
+	case q.Scope == ScopeChannel:
+		filter, args = "guild_id = ? AND channel_id = ?", []interface{}{q.GuildID, q.ChannelID}
+	case q.Scope == ScopeGuild:
+		filter, args = "guild_id = ?", []interface{}{q.GuildID}
+	case q.Scope == ScopeGlobal:
+		filter, args = "user_id = ?", []interface{}{q.UserID}
+	default:
+		filter, args = "guild_id = ? AND user_id = ?", []interface{}{q.GuildID, q.UserID}
+	}
+	if q.EmbeddingModel != "" {
+		filter += " AND embedding_model = ?"
+		args = append(args, q.EmbeddingModel)
+	}
+
+	query := `
+        SELECT id, guild_id, channel_id, user_id, session_id, message, response, embedding, embedding_model, created_at, updated_at
+        FROM conversations
+        WHERE ` + filter
+
+	rows, err := vs.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search similar conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []Conversation
+	for rows.Next() {
+		var conv Conversation
+		var embedding []byte
+		err := rows.Scan(
+			&conv.ID,
+			&conv.GuildID,
+			&conv.ChannelID,
+			&conv.UserID,
+			&conv.SessionID,
+			&conv.Message,
+			&conv.Response,
+			&embedding,
+			&conv.EmbeddingModel,
+			&conv.CreatedAt,
+			&conv.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conv.Embedding = decodeEmbedding(embedding)
+		candidates = append(candidates, conv)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversations: %w", err)
+	}
+
+	return rankConversations(candidates, q), nil
+}
+
+func (vs *SQLiteVectorStore) GetGuildSettings(ctx context.Context, guildID string) (GuildSettings, error) {
+	query := `
+        SELECT guild_id, humor, honesty, verbosity, memory_scope, always_listen,
+            tts_model, tts_voice, tts_speed, tts_format, updated_at
+        FROM guild_settings
+        WHERE guild_id = ?
+    `
+
+	settings := DefaultGuildSettings(guildID)
+	err := vs.db.QueryRowContext(ctx, query, guildID).Scan(
+		&settings.GuildID,
+		&settings.Humor,
+		&settings.Honesty,
+		&settings.Verbosity,
+		&settings.MemoryScope,
+		&settings.AlwaysListen,
+		&settings.TTSModel,
+		&settings.TTSVoice,
+		&settings.TTSSpeed,
+		&settings.TTSFormat,
+		&settings.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultGuildSettings(guildID), nil
+	}
+	if err != nil {
+		return GuildSettings{}, fmt.Errorf("failed to load guild settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func (vs *SQLiteVectorStore) SaveGuildSettings(ctx context.Context, settings GuildSettings) error {
+	query := `
+        INSERT INTO guild_settings (guild_id, humor, honesty, verbosity, memory_scope, always_listen,
+            tts_model, tts_voice, tts_speed, tts_format, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT (guild_id) DO UPDATE SET
+            humor = excluded.humor,
+            honesty = excluded.honesty,
+            verbosity = excluded.verbosity,
+            memory_scope = excluded.memory_scope,
+            always_listen = excluded.always_listen,
+            tts_model = excluded.tts_model,
+            tts_voice = excluded.tts_voice,
+            tts_speed = excluded.tts_speed,
+            tts_format = excluded.tts_format,
+            updated_at = excluded.updated_at
+    `
+
+	_, err := vs.db.ExecContext(ctx, query,
+		settings.GuildID, settings.Humor, settings.Honesty, settings.Verbosity,
+		string(settings.MemoryScope), settings.AlwaysListen,
+		settings.TTSModel, settings.TTSVoice, settings.TTSSpeed, settings.TTSFormat, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save guild settings: %w", err)
+	}
+
+	return nil
+}
+
+func (vs *SQLiteVectorStore) PendingReembedding(ctx context.Context, model string, afterID, limit int) ([]Conversation, error) {
+	query := `
+        SELECT id, message, response
+        FROM conversations
+        WHERE id > ? AND embedding_model != ?
+        ORDER BY id
+        LIMIT ?
+    `
+
+	rows, err := vs.db.QueryContext(ctx, query, afterID, model, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations to re-embed: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []Conversation
+	for rows.Next() {
+		var conv Conversation
+		err := rows.Scan(&conv.ID, &conv.Message, &conv.Response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	return conversations, rows.Err()
+}
+
+func (vs *SQLiteVectorStore) UpdateEmbedding(ctx context.Context, id int, model string, embedding []float32) error {
+	query := `
+        UPDATE conversations
+        SET embedding = ?, embedding_model = ?, updated_at = ?
+        WHERE id = ?
+    `
+
+	_, err := vs.db.ExecContext(ctx, query, encodeEmbedding(embedding), model, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update embedding: %w", err)
+	}
+
+	return nil
+}
+
+// BeginReembedding is a no-op: blobs of any dimension can coexist.
+func (vs *SQLiteVectorStore) BeginReembedding(ctx context.Context, dimensions int) error {
+	return nil
+}
+
+func (vs *SQLiteVectorStore) FinishReembedding(ctx context.Context, dimensions int) error {
+	return nil
+}
+
+func (vs *SQLiteVectorStore) SaveSession(ctx context.Context, session Session) error {
+	query := `
+        INSERT INTO sessions (channel_id, session_id, guild_id, user_id, created_at)
+        VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT (channel_id) DO UPDATE SET
+            session_id = excluded.session_id,
+            guild_id = excluded.guild_id,
+            user_id = excluded.user_id,
+            created_at = excluded.created_at
+    `
+
+	_, err := vs.db.ExecContext(ctx, query,
+		session.ChannelID, session.SessionID, session.GuildID, session.UserID, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return nil
+}
+
+func (vs *SQLiteVectorStore) GetSession(ctx context.Context, channelID string) (Session, bool, error) {
+	query := `
+        SELECT channel_id, session_id, guild_id, user_id, created_at
+        FROM sessions
+        WHERE channel_id = ?
+    `
+
+	var session Session
+	err := vs.db.QueryRowContext(ctx, query, channelID).Scan(
+		&session.ChannelID,
+		&session.SessionID,
+		&session.GuildID,
+		&session.UserID,
+		&session.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	return session, true, nil
+}
+
+func (vs *SQLiteVectorStore) Close() error {
+	return vs.db.Close()
+}
+
+func encodeEmbedding(embedding []float32) []byte {
+	data := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(value))
+	}
+	return data
+}
+
+func decodeEmbedding(data []byte) []float32 {
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return embedding
+}
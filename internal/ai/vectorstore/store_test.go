@@ -0,0 +1,404 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testStores runs fn against a fresh instance of every store. PostgreSQL is
+// only tested when TEST_POSTGRES_CONN_STRING points to a database with the
+// pgvector extension available.
+func testStores(t *testing.T, fn func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, NewInMemoryVectorStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := NewSQLiteVectorStore(filepath.Join(t.TempDir(), "tars.db"), "new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		fn(t, store)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		connString := os.Getenv("TEST_POSTGRES_CONN_STRING")
+		if connString == "" {
+			t.Skip("TEST_POSTGRES_CONN_STRING not set")
+		}
+		fn(t, newTestPostgreSQLStore(t, connString))
+	})
+}
+
+// newTestPostgreSQLStore migrates a schema of its own, dropped once the test
+// ends, with 2-dimensional embeddings.
+func newTestPostgreSQLStore(t *testing.T, connString string) *PostgreSQLVectorStore {
+	t.Helper()
+	ctx := context.Background()
+
+	admin, err := NewPostgreSQLPool(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("tars_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector; CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE") })
+
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := migrateUp(pool, MigrationParams{EmbeddingDimensions: 2, EmbeddingModel: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	return &PostgreSQLVectorStore{pool: pool}
+}
+
+// storeFixtures saves conversations named after where they were held, all
+// close to the query embedding {1, 0} unless noted.
+func storeFixtures(t *testing.T, store Store) {
+	t.Helper()
+
+	fixtures := []Conversation{
+		{Message: "g1 c1 u1", GuildID: "g1", ChannelID: "c1", UserID: "u1", Embedding: []float32{1, 0}},
+		{Message: "g1 c1 u2", GuildID: "g1", ChannelID: "c1", UserID: "u2", Embedding: []float32{1, 0}},
+		{Message: "g1 c2 u1", GuildID: "g1", ChannelID: "c2", UserID: "u1", Embedding: []float32{1, 0}},
+		{Message: "g2 c3 u1", GuildID: "g2", ChannelID: "c3", UserID: "u1", Embedding: []float32{1, 0}},
+		{Message: "session", GuildID: "g1", ChannelID: "t1", UserID: "u1", SessionID: "s1", Embedding: []float32{1, 0}},
+		{Message: "unrelated", GuildID: "g1", ChannelID: "c1", UserID: "u1", Embedding: []float32{0, 1}},
+		{Message: "old model", GuildID: "g1", ChannelID: "c1", UserID: "u1", Embedding: []float32{1, 0}, EmbeddingModel: "old"},
+	}
+	for _, conv := range fixtures {
+		if conv.EmbeddingModel == "" {
+			conv.EmbeddingModel = "new"
+		}
+		conv.Response = "ok"
+		if err := store.StoreConversation(context.Background(), conv); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStoreSearchScopes(t *testing.T) {
+	base := SearchQuery{
+		GuildID:        "g1",
+		ChannelID:      "c1",
+		UserID:         "u1",
+		Embedding:      []float32{1, 0},
+		EmbeddingModel: "new",
+		Mode:           SearchVector,
+		MinSimilarity:  0.5,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(q *SearchQuery)
+		want   []string
+	}{
+		{
+			name:   "user",
+			modify: func(q *SearchQuery) { q.Scope = ScopeUser },
+			want:   []string{"g1 c1 u1", "g1 c2 u1", "session"},
+		},
+		{
+			name:   "default scope is user",
+			modify: func(q *SearchQuery) {},
+			want:   []string{"g1 c1 u1", "g1 c2 u1", "session"},
+		},
+		{
+			name:   "channel",
+			modify: func(q *SearchQuery) { q.Scope = ScopeChannel },
+			want:   []string{"g1 c1 u1", "g1 c1 u2"},
+		},
+		{
+			name:   "guild",
+			modify: func(q *SearchQuery) { q.Scope = ScopeGuild },
+			want:   []string{"g1 c1 u1", "g1 c1 u2", "g1 c2 u1", "session"},
+		},
+		{
+			name:   "global",
+			modify: func(q *SearchQuery) { q.Scope = ScopeGlobal },
+			want:   []string{"g1 c1 u1", "g1 c2 u1", "g2 c3 u1", "session"},
+		},
+		{
+			name:   "session overrides scope",
+			modify: func(q *SearchQuery) { q.Scope = ScopeGuild; q.SessionID = "s1" },
+			want:   []string{"session"},
+		},
+		{
+			name:   "no minimum similarity",
+			modify: func(q *SearchQuery) { q.MinSimilarity = 0 },
+			want:   []string{"g1 c1 u1", "g1 c2 u1", "session", "unrelated"},
+		},
+		{
+			name:   "any embedding model",
+			modify: func(q *SearchQuery) { q.EmbeddingModel = "" },
+			want:   []string{"g1 c1 u1", "g1 c2 u1", "old model", "session"},
+		},
+		{
+			name:   "limit",
+			modify: func(q *SearchQuery) { q.Scope = ScopeGuild; q.Limit = 2 },
+			want:   []string{"g1 c1 u1", "g1 c1 u2"},
+		},
+	}
+
+	testStores(t, func(t *testing.T, store Store) {
+		storeFixtures(t, store)
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				q := base
+				tt.modify(&q)
+				results, err := store.SearchSimilar(context.Background(), q)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var got []string
+				for _, conv := range results {
+					got = append(got, conv.Message)
+				}
+				slices.Sort(got)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestStoreSearchOrdersBySimilarity(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		for _, conv := range []Conversation{
+			{Message: "far", Embedding: []float32{0.6, 0.8}},
+			{Message: "exact", Embedding: []float32{1, 0}},
+			{Message: "near", Embedding: []float32{0.8, 0.6}},
+		} {
+			conv.GuildID, conv.UserID = "g", "u"
+			if err := store.StoreConversation(context.Background(), conv); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		results, err := store.SearchSimilar(context.Background(), SearchQuery{
+			GuildID:   "g",
+			UserID:    "u",
+			Embedding: []float32{1, 0},
+			Mode:      SearchVector,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, conv := range results {
+			got = append(got, conv.Message)
+		}
+		if want := []string{"exact", "near", "far"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if len(results) > 0 && (results[0].Similarity < 0.999 || results[0].Score != results[0].Similarity) {
+			t.Errorf("top result similarity %v, score %v, want 1", results[0].Similarity, results[0].Score)
+		}
+	})
+}
+
+func TestStoreHybridSearch(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		for _, conv := range []Conversation{
+			{Message: "what is the weather like", Response: "sunny", Embedding: []float32{1, 0}},
+			{Message: "my cat is called pineapple", Response: "noted", Embedding: []float32{0, 1}},
+			{Message: "tell me a joke", Response: "no", Embedding: []float32{-1, 0}},
+		} {
+			conv.GuildID, conv.UserID = "g", "u"
+			if err := store.StoreConversation(context.Background(), conv); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		results, err := store.SearchSimilar(context.Background(), SearchQuery{
+			GuildID:       "g",
+			UserID:        "u",
+			Embedding:     []float32{1, 0},
+			Mode:          SearchHybrid,
+			Text:          "what was pineapple again",
+			MinSimilarity: 0.5,
+			Limit:         5,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, conv := range results {
+			got = append(got, conv.Message)
+		}
+		slices.Sort(got)
+		// The lexical match is kept despite its dissimilar embedding
+		if want := []string{"my cat is called pineapple", "what is the weather like"}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestStoreGuildSettings(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		settings, err := store.GetGuildSettings(ctx, "g1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if settings != DefaultGuildSettings("g1") {
+			t.Errorf("got %+v for a new guild, want the defaults", settings)
+		}
+
+		settings.Humor = 10
+		settings.MemoryScope = ScopeChannel
+		settings.AlwaysListen = true
+		settings.TTSVoice = "nova"
+		settings.TTSSpeed = 1.5
+		settings.TTSFormat = "pcm"
+		if err := store.SaveGuildSettings(ctx, settings); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := store.GetGuildSettings(ctx, "g1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.UpdatedAt = settings.UpdatedAt
+		if got != settings {
+			t.Errorf("got %+v, want %+v", got, settings)
+		}
+	})
+}
+
+func TestStoreSessions(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		if _, ok, err := store.GetSession(ctx, "t1"); err != nil || ok {
+			t.Fatalf("got session %v, error %v for an unknown channel", ok, err)
+		}
+
+		want := Session{ChannelID: "t1", SessionID: "s1", GuildID: "g1", UserID: "u1"}
+		if err := store.SaveSession(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok, err := store.GetSession(ctx, "t1")
+		if err != nil || !ok {
+			t.Fatalf("got session %v, error %v", ok, err)
+		}
+		got.CreatedAt = want.CreatedAt
+		if got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestStoreReembedding(t *testing.T) {
+	testStores(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		storeFixtures(t, store)
+
+		pending, err := store.PendingReembedding(ctx, "new", 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != 1 || pending[0].Message != "old model" {
+			t.Fatalf("got %+v, want only the old model conversation", pending)
+		}
+
+		if err := store.UpdateEmbedding(ctx, pending[0].ID, "new", []float32{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+		pending, err = store.PendingReembedding(ctx, "new", 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != 0 {
+			t.Errorf("got %d pending after re-embedding, want none", len(pending))
+		}
+
+		// Switching models leaves everything pending, paged by ID
+		first, err := store.PendingReembedding(ctx, "newer", 0, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest, err := store.PendingReembedding(ctx, "newer", first[len(first)-1].ID, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(first) != 4 || len(rest) != 3 {
+			t.Errorf("got pages of %d and %d, want 4 and 3", len(first), len(rest))
+		}
+	})
+}
+
+func TestSQLiteBackfillsEmbeddingModel(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tars.db")
+
+	// A database from before embedding models were tracked
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+        CREATE TABLE conversations (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            guild_id TEXT NOT NULL DEFAULT '',
+            channel_id TEXT NOT NULL DEFAULT '',
+            user_id TEXT NOT NULL,
+            session_id TEXT NOT NULL DEFAULT '',
+            message TEXT NOT NULL,
+            response TEXT NOT NULL,
+            embedding BLOB,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        );
+        INSERT INTO conversations (user_id, message, response, created_at, updated_at)
+        VALUES ('u1', 'hello', 'hi', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+    `)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewSQLiteVectorStore(path, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// The existing row counts as embedded by the configured model
+	for model, want := range map[string]int{"old": 0, "new": 1} {
+		pending, err := store.PendingReembedding(ctx, model, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != want {
+			t.Errorf("got %d conversations to re-embed with %s, want %d", len(pending), model, want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// OpusSampleRate is the rate granule positions are counted in, whatever
+	// the input rate.
+	OpusSampleRate = 48000
+	// opusPreSkip is the number of samples the decoder discards at start.
+	opusPreSkip = 312
+	// packetsPerPage keeps pages around one second of 20ms packets.
+	packetsPerPage = 50
+	// maxPageSegments is the number of lacing values a page can hold.
+	maxPageSegments = 255
+
+	oggHeaderContinued = 0x01
+	oggHeaderBOS       = 0x02
+	oggHeaderEOS       = 0x04
+)
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// EncodeOggOpus muxes raw Opus packets, as received from Discord, into an
+// Ogg/Opus file (RFC 7845) without re-encoding them.
+func EncodeOggOpus(packets [][]byte, channels int) ([]byte, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("no opus packets to encode")
+	}
+
+	w := &oggWriter{serial: 0x54415253} // "TARS"
+
+	// Identification header
+	head := bytes.NewBuffer(nil)
+	head.WriteString("OpusHead")
+	head.WriteByte(1)
+	head.WriteByte(byte(channels))
+	binary.Write(head, binary.LittleEndian, uint16(opusPreSkip))
+	binary.Write(head, binary.LittleEndian, uint32(OpusSampleRate))
+	binary.Write(head, binary.LittleEndian, int16(0))
+	head.WriteByte(0)
+	w.writePage(lacingValues(head.Len()), head.Bytes(), 0, oggHeaderBOS)
+
+	// Comment header
+	vendor := "tars-bot"
+	tags := bytes.NewBuffer(nil)
+	tags.WriteString("OpusTags")
+	binary.Write(tags, binary.LittleEndian, uint32(len(vendor)))
+	tags.WriteString(vendor)
+	binary.Write(tags, binary.LittleEndian, uint32(0))
+	w.writePage(lacingValues(tags.Len()), tags.Bytes(), 0, 0)
+
+	w.writeAudio(packets)
+
+	return w.buf.Bytes(), nil
+}
+
+// OpusPacketSamples returns the duration of an Opus packet in samples at
+// 48kHz, read from its TOC byte (RFC 6716 section 3.1).
+func OpusPacketSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+
+	toc := packet[0]
+	config := toc >> 3
+
+	var frameSize int
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frameSize = []int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10, 20 ms
+		frameSize = []int{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frameSize = []int{120, 240, 480, 960}[config%4]
+	}
+
+	var frames int
+	switch toc & 0x03 {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+
+	return frameSize * frames
+}
+
+type oggWriter struct {
+	buf      bytes.Buffer
+	serial   uint32
+	sequence uint32
+}
+
+// writeAudio writes packets as pages of up to packetsPerPage packets, the
+// last one marked EOS. A page that runs out of lacing values is closed
+// early, and the packet it ends in continues on the next page.
+func (w *oggWriter) writeAudio(packets [][]byte) {
+	var (
+		lacing, body []byte
+		headerType   byte
+		samples      int64
+		// granule is the number of samples decoded once the last packet
+		// completing on the page does, or -1 if none completes on it
+		granule     int64 = -1
+		pagePackets int
+	)
+	flush := func() {
+		w.writePage(lacing, body, granule, headerType)
+		lacing, body, headerType, granule, pagePackets = nil, nil, 0, -1, 0
+	}
+
+	for i, packet := range packets {
+		for j, size := range lacingValues(len(packet)) {
+			if len(lacing) == maxPageSegments {
+				flush()
+				if j > 0 {
+					headerType = oggHeaderContinued
+				}
+			}
+			lacing = append(lacing, size)
+			body = append(body, packet[j*255:j*255+int(size)]...)
+		}
+
+		samples += int64(OpusPacketSamples(packet))
+		granule = samples + opusPreSkip
+		pagePackets++
+		if pagePackets == packetsPerPage && i < len(packets)-1 {
+			flush()
+		}
+	}
+
+	headerType |= oggHeaderEOS
+	flush()
+}
+
+// lacingValues returns the segment sizes of a packet of size bytes, ending
+// with a value under 255 so that the packet's end is unambiguous.
+func lacingValues(size int) []byte {
+	lacing := bytes.Repeat([]byte{255}, size/255)
+	return append(lacing, byte(size%255))
+}
+
+// writePage writes one Ogg page holding body, split into segments by lacing.
+// Callers keep lacing within maxPageSegments.
+func (w *oggWriter) writePage(lacing, body []byte, granule int64, headerType byte) {
+	page := bytes.NewBuffer(nil)
+	page.WriteString("OggS")
+	page.WriteByte(0)
+	page.WriteByte(headerType)
+	binary.Write(page, binary.LittleEndian, granule)
+	binary.Write(page, binary.LittleEndian, w.serial)
+	binary.Write(page, binary.LittleEndian, w.sequence)
+	binary.Write(page, binary.LittleEndian, uint32(0)) // CRC, filled below
+	page.WriteByte(byte(len(lacing)))
+	page.Write(lacing)
+	page.Write(body)
+
+	data := page.Bytes()
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(data[22:], crc)
+
+	w.buf.Write(data)
+	w.sequence++
+}
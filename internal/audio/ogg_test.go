@@ -0,0 +1,239 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oggPage is a parsed Ogg page with the packets completing on it reassembled
+// from the lacing.
+type oggPage struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	sequence   uint32
+	segments   int
+	packets    [][]byte
+}
+
+// parseOggPages splits data into pages, checking each page's CRC and that
+// packets carried over from the previous page are flagged as continued.
+func parseOggPages(t *testing.T, data []byte) []oggPage {
+	t.Helper()
+
+	var pages []oggPage
+	var packet []byte
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[0:4]) != "OggS" {
+			t.Fatalf("page %d has no capture pattern", len(pages))
+		}
+		segments := int(data[26])
+		lacing := data[27 : 27+segments]
+		size := 27 + segments
+		for _, l := range lacing {
+			size += int(l)
+		}
+		raw := append([]byte(nil), data[:size]...)
+
+		want := binary.LittleEndian.Uint32(raw[22:26])
+		binary.LittleEndian.PutUint32(raw[22:26], 0)
+		var crc uint32
+		for _, b := range raw {
+			crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+		}
+		if crc != want {
+			t.Errorf("page %d has CRC %08x, want %08x", len(pages), want, crc)
+		}
+
+		page := oggPage{
+			headerType: data[5],
+			granule:    int64(binary.LittleEndian.Uint64(data[6:14])),
+			serial:     binary.LittleEndian.Uint32(data[14:18]),
+			sequence:   binary.LittleEndian.Uint32(data[18:22]),
+			segments:   segments,
+		}
+		if continued := page.headerType&oggHeaderContinued != 0; continued != (packet != nil) {
+			t.Errorf("page %d has continued flag %v, want %v", len(pages), continued, packet != nil)
+		}
+		body := data[27+segments : size]
+		for _, l := range lacing {
+			packet = append(packet, body[:l]...)
+			body = body[l:]
+			if l < 255 {
+				page.packets = append(page.packets, packet)
+				packet = nil
+			}
+		}
+		pages = append(pages, page)
+		data = data[size:]
+	}
+	if packet != nil {
+		t.Error("stream ends inside a packet")
+	}
+	return pages
+}
+
+// opusPacket returns a CELT 20ms single-frame packet of size bytes.
+func opusPacket(size int, fill byte) []byte {
+	packet := bytes.Repeat([]byte{fill}, size)
+	packet[0] = 31 << 3
+	return packet
+}
+
+func TestEncodeOggOpus(t *testing.T) {
+	var packets [][]byte
+	for i := 0; i < 120; i++ {
+		size := 40
+		switch i {
+		case 3:
+			size = 255 // needs a terminating zero lacing value
+		case 4:
+			size = 600 // spans three lacing values
+		}
+		packets = append(packets, opusPacket(size, byte(i)))
+	}
+
+	data, err := EncodeOggOpus(packets, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := parseOggPages(t, data)
+
+	// Two header pages, then 50, 50 and 20 packets
+	if len(pages) != 5 {
+		t.Fatalf("got %d pages, want 5", len(pages))
+	}
+	for i, page := range pages {
+		if page.sequence != uint32(i) {
+			t.Errorf("page %d has sequence %d", i, page.sequence)
+		}
+		if page.serial != pages[0].serial {
+			t.Errorf("page %d changes the stream serial", i)
+		}
+	}
+
+	if pages[0].headerType != oggHeaderBOS || pages[4].headerType != oggHeaderEOS {
+		t.Errorf("got header types %d and %d, want BOS first and EOS last", pages[0].headerType, pages[4].headerType)
+	}
+	for _, page := range pages[1:4] {
+		if page.headerType != 0 {
+			t.Errorf("middle page has header type %d", page.headerType)
+		}
+	}
+
+	head := pages[0].packets[0]
+	if string(head[0:8]) != "OpusHead" || head[9] != 2 || binary.LittleEndian.Uint16(head[10:12]) != opusPreSkip {
+		t.Errorf("bad identification header %v", head)
+	}
+	if string(pages[1].packets[0][0:8]) != "OpusTags" {
+		t.Error("second page is not the comment header")
+	}
+
+	// Granule positions count 960 samples per packet plus the pre-skip
+	wantGranules := []int64{0, 0, 50*960 + opusPreSkip, 100*960 + opusPreSkip, 120*960 + opusPreSkip}
+	for i, page := range pages {
+		if page.granule != wantGranules[i] {
+			t.Errorf("page %d has granule %d, want %d", i, page.granule, wantGranules[i])
+		}
+	}
+
+	var got [][]byte
+	for _, page := range pages[2:] {
+		got = append(got, page.packets...)
+	}
+	if len(got) != len(packets) {
+		t.Fatalf("got %d packets back, want %d", len(got), len(packets))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d differs after muxing", i)
+		}
+	}
+}
+
+func TestEncodeOggOpusLargePackets(t *testing.T) {
+	// Packets of six to eight lacing values each, so fifty of them do not
+	// fit on one page and packets straddle page boundaries, then one too
+	// large for a page of its own.
+	var packets [][]byte
+	for i := 0; i < 60; i++ {
+		packets = append(packets, opusPacket(1275+i%3*255, byte(i)))
+	}
+	packets = append(packets, opusPacket(600*255, 0xff))
+
+	data, err := EncodeOggOpus(packets, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := parseOggPages(t, data)
+
+	var wantSegments, gotSegments int
+	for _, packet := range packets {
+		wantSegments += len(packet)/255 + 1
+	}
+	var got [][]byte
+	for i, page := range pages[2:] {
+		if page.segments == 0 {
+			t.Errorf("audio page %d is empty", i)
+		}
+		gotSegments += page.segments
+
+		// Pages where no packet completes have no granule position
+		wantGranule := int64(-1)
+		if len(page.packets) > 0 {
+			wantGranule = int64(len(got)+len(page.packets))*960 + opusPreSkip
+		}
+		if page.granule != wantGranule {
+			t.Errorf("audio page %d has granule %d, want %d", i, page.granule, wantGranule)
+		}
+		got = append(got, page.packets...)
+	}
+	if gotSegments != wantSegments {
+		t.Errorf("got %d segments across audio pages, want %d", gotSegments, wantSegments)
+	}
+	if last := pages[len(pages)-1]; last.headerType&oggHeaderEOS == 0 {
+		t.Error("last page is not marked EOS")
+	}
+
+	if len(got) != len(packets) {
+		t.Fatalf("got %d packets back, want %d", len(got), len(packets))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d differs after muxing", i)
+		}
+	}
+}
+
+func TestEncodeOggOpusEmpty(t *testing.T) {
+	if _, err := EncodeOggOpus(nil, 2); err == nil {
+		t.Error("expected an error for no packets")
+	}
+}
+
+func TestOpusPacketSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   int
+	}{
+		{"empty", nil, 0},
+		{"SILK 10ms", []byte{0 << 3}, 480},
+		{"SILK 60ms", []byte{3 << 3}, 2880},
+		{"hybrid 20ms", []byte{13 << 3}, 960},
+		{"CELT 2.5ms", []byte{16 << 3}, 120},
+		{"CELT 20ms", []byte{31 << 3}, 960},
+		{"two frames", []byte{31<<3 | 1}, 1920},
+		{"two frames of different sizes", []byte{31<<3 | 2}, 1920},
+		{"arbitrary frame count", []byte{31<<3 | 3, 3}, 2880},
+		{"truncated frame count", []byte{31<<3 | 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OpusPacketSamples(tt.packet); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package audio
+
+// Resample converts interleaved PCM between sample rates using linear
+// interpolation, which is plenty for speech headed to a voice codec.
+func Resample(pcm []int16, channels, fromRate, toRate int) []int16 {
+	if fromRate == toRate || len(pcm) == 0 {
+		return pcm
+	}
+
+	inFrames := len(pcm) / channels
+	outFrames := int(int64(inFrames) * int64(toRate) / int64(fromRate))
+	out := make([]int16, outFrames*channels)
+
+	step := float64(fromRate) / float64(toRate)
+	for i := 0; i < outFrames; i++ {
+		pos := float64(i) * step
+		j := int(pos)
+		frac := pos - float64(j)
+		next := j + 1
+		if next >= inFrames {
+			next = inFrames - 1
+		}
+
+		for c := 0; c < channels; c++ {
+			a := float64(pcm[j*channels+c])
+			b := float64(pcm[next*channels+c])
+			out[i*channels+c] = int16(a + (b-a)*frac)
+		}
+	}
+
+	return out
+}
+
+// ToStereo duplicates mono samples into both channels, or downmixes
+// multichannel audio to its first two channels.
+func ToStereo(pcm []int16, channels int) []int16 {
+	if channels == 2 {
+		return pcm
+	}
+
+	frames := len(pcm) / channels
+	out := make([]int16, frames*2)
+	for i := 0; i < frames; i++ {
+		left := pcm[i*channels]
+		right := left
+		if channels > 1 {
+			right = pcm[i*channels+1]
+		}
+		out[2*i], out[2*i+1] = left, right
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []int16
+		channels int
+		from, to int
+		want     []int16
+	}{
+		{"same rate", []int16{1, 2, 3}, 1, 48000, 48000, []int16{1, 2, 3}},
+		{"empty", nil, 1, 24000, 48000, nil},
+		{"upsample interpolates", []int16{0, 100, 200}, 1, 24000, 48000, []int16{0, 50, 100, 150, 200, 200}},
+		{"downsample", []int16{0, 10, 20, 30, 40, 50}, 1, 48000, 24000, []int16{0, 20, 40}},
+		{"stereo keeps channels apart", []int16{0, 1000, 100, 900}, 2, 24000, 48000, []int16{0, 1000, 50, 950, 100, 900, 100, 900}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Resample(tt.pcm, tt.channels, tt.from, tt.to); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResampleLength(t *testing.T) {
+	pcm := make([]int16, 22050*2)
+	if got := len(Resample(pcm, 2, 22050, 48000)); got != 48000*2 {
+		t.Errorf("one second resampled to %d samples, want %d", got, 48000*2)
+	}
+}
+
+func TestToStereo(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []int16
+		channels int
+		want     []int16
+	}{
+		{"mono", []int16{1, 2}, 1, []int16{1, 1, 2, 2}},
+		{"stereo", []int16{1, 2, 3, 4}, 2, []int16{1, 2, 3, 4}},
+		{"surround", []int16{1, 2, 3, 4, 5, 6}, 3, []int16{1, 2, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToStereo(tt.pcm, tt.channels); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownmixStereo(t *testing.T) {
+	got := DownmixStereo([]int16{100, 300, -32768, -32768, 32767, 32767})
+	if want := []int16{200, -32768, 32767}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
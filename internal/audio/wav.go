@@ -0,0 +1,110 @@
+// Package audio holds the pure-Go audio helpers of the voice pipeline:
+// containers, sample conversion and resampling.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Limits of the WAV files DecodeWAV accepts. Speech services stay well
+// inside them; anything outside is a corrupt header.
+const (
+	maxWAVChannels   = 8
+	minWAVSampleRate = 8000
+	maxWAVSampleRate = 192000
+)
+
+// EncodeWAV wraps interleaved 16-bit PCM samples in a RIFF/WAVE container.
+func EncodeWAV(pcm []int16, sampleRate, channels int) []byte {
+	dataSize := len(pcm) * 2
+	blockAlign := channels * 2
+
+	buf := bytes.NewBuffer(make([]byte, 0, 44+dataSize))
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+
+	// fmt chunk: uncompressed PCM
+	buf.WriteString("fmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(1))
+	binary.Write(buf, binary.LittleEndian, uint16(channels))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(buf, binary.LittleEndian, uint16(16))
+
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, uint32(dataSize))
+	binary.Write(buf, binary.LittleEndian, pcm)
+
+	return buf.Bytes()
+}
+
+// DecodeWAV returns the interleaved samples of a 16-bit PCM WAV file along
+// with its sample rate and channel count. Streamed WAV files often carry
+// placeholder chunk sizes, so a data chunk running past the end of the input
+// is read up to the end.
+func DecodeWAV(data []byte) (pcm []int16, sampleRate, channels int, err error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, errors.New("not a WAV file")
+	}
+
+	var haveFormat bool
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := data[offset+8:]
+		if size >= 0 && size < len(body) {
+			body = body[:size]
+		}
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, 0, 0, errors.New("truncated fmt chunk")
+			}
+			format := binary.LittleEndian.Uint16(body[0:2])
+			bitsPerSample := binary.LittleEndian.Uint16(body[14:16])
+			// 0xFFFE is WAVE_FORMAT_EXTENSIBLE, used by some encoders for plain PCM
+			if (format != 1 && format != 0xFFFE) || bitsPerSample != 16 {
+				return nil, 0, 0, fmt.Errorf("unsupported WAV encoding (format %d, %d bits)", format, bitsPerSample)
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			if channels < 1 || channels > maxWAVChannels {
+				return nil, 0, 0, fmt.Errorf("unsupported WAV channel count %d", channels)
+			}
+			if sampleRate < minWAVSampleRate || sampleRate > maxWAVSampleRate {
+				return nil, 0, 0, fmt.Errorf("unsupported WAV sample rate %d", sampleRate)
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, 0, 0, errors.New("data chunk before fmt chunk")
+			}
+			pcm = make([]int16, len(body)/2)
+			for i := range pcm {
+				pcm[i] = int16(binary.LittleEndian.Uint16(body[2*i:]))
+			}
+			return pcm, sampleRate, channels, nil
+		}
+
+		// Chunks are padded to an even size
+		offset += 8 + len(body) + len(body)%2
+	}
+
+	return nil, 0, 0, errors.New("WAV file has no data chunk")
+}
+
+// DownmixStereo averages interleaved stereo samples into mono.
+func DownmixStereo(pcm []int16) []int16 {
+	mono := make([]int16, len(pcm)/2)
+	for i := range mono {
+		mono[i] = int16((int32(pcm[2*i]) + int32(pcm[2*i+1])) / 2)
+	}
+	return mono
+}
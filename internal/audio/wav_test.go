@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	pcm := []int16{0, 1, -1, 32767, -32768, 1234, -4321, 7}
+	gotPCM, rate, ch, err := DecodeWAV(EncodeWAV(pcm, 24000, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 24000 || ch != 2 {
+		t.Errorf("got %d Hz, %d channels, want 24000 Hz, 2 channels", rate, ch)
+	}
+	if !slices.Equal(gotPCM, pcm) {
+		t.Errorf("got samples %v, want %v", gotPCM, pcm)
+	}
+}
+
+func TestDecodeWAVStreamedSizes(t *testing.T) {
+	pcm := []int16{1, 2, 3, 4}
+	data := EncodeWAV(pcm, 16000, 1)
+	// Streaming encoders write placeholder sizes they never patch
+	binary.LittleEndian.PutUint32(data[4:8], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(data[40:44], 0xFFFFFFFF)
+
+	got, _, _, err := DecodeWAV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, pcm) {
+		t.Errorf("got samples %v, want %v", got, pcm)
+	}
+}
+
+func TestDecodeWAVRejectsBadHeaders(t *testing.T) {
+	valid := func() []byte { return EncodeWAV([]int16{1, 2, 3, 4}, 24000, 1) }
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{"not RIFF", func(data []byte) []byte { copy(data, "RIFX"); return data }},
+		{"too short", func(data []byte) []byte { return data[:8] }},
+		{"zero channels", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[22:24], 0)
+			return data
+		}},
+		{"too many channels", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[22:24], 64)
+			return data
+		}},
+		{"zero sample rate", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[24:28], 0)
+			return data
+		}},
+		{"huge sample rate", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[24:28], 10_000_000)
+			return data
+		}},
+		{"8-bit samples", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[34:36], 8)
+			return data
+		}},
+		{"float format", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[20:22], 3)
+			return data
+		}},
+		{"no data chunk", func(data []byte) []byte { return data[:36] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := DecodeWAV(tt.modify(valid())); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
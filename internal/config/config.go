@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +14,54 @@ type Config struct {
 	DiscordToken       string
 	OpenAIKey          string
 	PostgresConnString string
+
+	// Memory backend
+	MemoryBackend  string
+	SQLitePath     string
+	MigrateOnStart bool
+	SearchMode     string
+	MinSimilarity  float64
+
+	// LLM backend
+	LLMProvider  string
+	LLMBaseURL   string
+	LLMAPIKey    string
+	LLMModel     string
+	MaxToolSteps int
+
+	// Embeddings
+	EmbeddingModel      string
+	EmbeddingDimensions int
+	ReembedOnStart      bool
+
+	// Persona
+	PersonaTemplate string
+
+	// Threads
+	MentionThreads bool
+	ThreadHistory  int
+
+	// Voice
+	STTProvider        string
+	STTURL             string
+	TTSProvider        string
+	TTSURL             string
+	TTSVoices          []string
+	STTAudioFormat     string
+	STTLanguage        string
+	STTPrompt          string
+	STTResponseFormat  string
+	STTMinConfidence   float64
+	VADSilenceTimeout  time.Duration
+	VADEnergyThreshold float64
+	MinUtterance       time.Duration
+	MaxUtterance       time.Duration
+	WakeWords          []string
+	WakeWordTolerance  float64
+	FollowUpWindow     time.Duration
+	TTSParallelism     int
+	VoiceIdleTimeout   time.Duration
+	VoiceTranscript    string
 }
 
 func Load() *Config {
@@ -24,5 +75,100 @@ func Load() *Config {
 		DiscordToken:       os.Getenv("DISCORD_TOKEN"),
 		OpenAIKey:          os.Getenv("OPENAI_API_KEY"),
 		PostgresConnString: os.Getenv("POSTGRES_CONN_STRING"),
+
+		MemoryBackend:  getEnv("MEMORY_BACKEND", "postgres"),
+		SQLitePath:     getEnv("SQLITE_PATH", "tars.db"),
+		MigrateOnStart: getEnvBool("MIGRATE_ON_START", true),
+		SearchMode:     getEnv("MEMORY_SEARCH_MODE", "hybrid"),
+		MinSimilarity:  getEnvFloat("MEMORY_MIN_SIMILARITY", 0.3),
+
+		LLMProvider:  getEnv("LLM_PROVIDER", "openai"),
+		LLMBaseURL:   os.Getenv("LLM_BASE_URL"),
+		LLMAPIKey:    os.Getenv("LLM_API_KEY"),
+		LLMModel:     os.Getenv("LLM_MODEL"),
+		MaxToolSteps: getEnvInt("LLM_MAX_TOOL_STEPS", 5),
+
+		EmbeddingModel:      os.Getenv("EMBEDDING_MODEL"),
+		EmbeddingDimensions: getEnvInt("EMBEDDING_DIMENSIONS", 1536),
+		ReembedOnStart:      getEnvBool("REEMBED_ON_START", false),
+
+		PersonaTemplate: os.Getenv("PERSONA_TEMPLATE"),
+
+		MentionThreads: getEnvBool("MENTION_THREADS", false),
+		ThreadHistory:  getEnvInt("THREAD_HISTORY", 20),
+
+		STTProvider:        getEnv("STT_PROVIDER", "openai"),
+		STTURL:             os.Getenv("STT_URL"),
+		TTSProvider:        getEnv("TTS_PROVIDER", "openai"),
+		TTSURL:             os.Getenv("TTS_URL"),
+		TTSVoices:          getEnvList("TTS_VOICES", nil),
+		STTAudioFormat:     getEnv("STT_AUDIO_FORMAT", "ogg"),
+		STTLanguage:        os.Getenv("STT_LANGUAGE"),
+		STTPrompt:          os.Getenv("STT_PROMPT"),
+		STTResponseFormat:  getEnv("STT_RESPONSE_FORMAT", "verbose_json"),
+		STTMinConfidence:   getEnvFloat("STT_MIN_CONFIDENCE", 0.4),
+		VADSilenceTimeout:  getEnvDuration("VAD_SILENCE_TIMEOUT", 800*time.Millisecond),
+		VADEnergyThreshold: getEnvFloat("VAD_ENERGY_THRESHOLD", 500),
+		MinUtterance:       getEnvDuration("MIN_UTTERANCE", 300*time.Millisecond),
+		MaxUtterance:       getEnvDuration("MAX_UTTERANCE", 15*time.Second),
+		WakeWords:          getEnvList("WAKE_WORDS", []string{"TARS"}),
+		WakeWordTolerance:  getEnvFloat("WAKE_WORD_TOLERANCE", 0.25),
+		FollowUpWindow:     getEnvDuration("FOLLOW_UP_WINDOW", 10*time.Second),
+		TTSParallelism:     getEnvInt("TTS_PARALLELISM", 3),
+		VoiceIdleTimeout:   getEnvDuration("VOICE_IDLE_TIMEOUT", 15*time.Minute),
+		VoiceTranscript:    getEnv("VOICE_TRANSCRIPT", "off"),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList splits a comma-separated variable, ignoring empty entries.
+func getEnvList(key string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
 	}
+	return values
 }
@@ -5,6 +5,7 @@ import (
 
 	"tars-bot/internal/ai"
 	"tars-bot/internal/config"
+	"tars-bot/internal/discord/voice"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -21,21 +22,31 @@ func NewBot(cfg *config.Config, agent *ai.AIAgent) (*Bot, error) {
 		return nil, err
 	}
 
-	// Configure intents
-	session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsGuildMessageReactions | discordgo.IntentsGuildVoiceStates
+	// Configure intents; message content is needed to read messages in
+	// conversation threads that do not mention the bot
+	session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsGuildMessageReactions |
+		discordgo.IntentsGuildVoiceStates | discordgo.IntentsMessageContent
 
-	return &Bot{
+	bot := &Bot{
 		Session: session,
 		Agent:   agent,
 		Config:  cfg,
-	}, nil
+	}
+
+	err = bot.registerTools()
+	if err != nil {
+		return nil, err
+	}
+
+	return bot, nil
 }
 
 func (b *Bot) Start() error {
 	// Register handlers
 	b.Session.AddHandler(b.readyHandler)
-	b.Session.AddHandler(b.mentionHandler)
+	b.Session.AddHandler(b.messageHandler)
 	b.Session.AddHandler(b.interactionHandler)
+	b.Session.AddHandler(b.voiceStateHandler)
 
 	// Open the websocket connection
 	err := b.Session.Open()
@@ -56,6 +67,12 @@ func (b *Bot) readyHandler(s *discordgo.Session, event *discordgo.Ready) {
 	log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 }
 
+func (b *Bot) voiceStateHandler(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+	if conn, exists := voice.GetActiveConnection(vsu.GuildID); exists {
+		conn.HandleVoiceStateUpdate(vsu)
+	}
+}
+
 func (b *Bot) Close() {
 	b.Session.Close()
 }
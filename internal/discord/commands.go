@@ -3,12 +3,43 @@ package discord
 import (
 	"log"
 
+	"tars-bot/internal/ai/vectorstore"
+
 	"github.com/bwmarrin/discordgo"
 )
 
+// Values of the /listen mode option
+const (
+	listenWakeWord = "wake_word"
+	listenAlways   = "always"
+)
+
+// maxAutocompleteChoices is the most suggestions Discord accepts in an
+// autocomplete response.
+const maxAutocompleteChoices = 25
+
 func (b *Bot) registerCommands() error {
-	// Register global commands
-	commands := []*discordgo.ApplicationCommand{
+	// Register commands globally
+	registeredCommands, err := b.Session.ApplicationCommandBulkOverwrite(b.Session.State.User.ID, "", applicationCommands())
+	if err != nil {
+		return err
+	}
+
+	log.Printf(" Registered %d commands", len(registeredCommands))
+	for _, command := range registeredCommands {
+		log.Printf(" Command: %v", command)
+	}
+	return nil
+}
+
+// applicationCommands returns the slash commands TARS registers. Each one
+// needs a matching case in commandHandler.
+func applicationCommands() []*discordgo.ApplicationCommand {
+	var minSetting float64 = 0
+	var minSpeed float64 = 0.25
+	var manageGuild int64 = discordgo.PermissionManageGuild
+
+	return []*discordgo.ApplicationCommand{
 		{
 			Name:        "chat",
 			Description: "Chat with the AI",
@@ -22,6 +53,18 @@ func (b *Bot) registerCommands() error {
 				},
 			},
 		},
+		{
+			Name:        "talk",
+			Description: "Start a thread to talk with TARS without mentioning it",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "message",
+					Description: "What you want to talk about",
+				},
+			},
+		},
 		{
 			Name:        "join",
 			Description: "Join a voice channel",
@@ -32,17 +75,120 @@ func (b *Bot) registerCommands() error {
 			Description: "Leave the voice channel",
 			Type:        discordgo.ChatApplicationCommand,
 		},
+		{
+			Name:        "stop",
+			Description: "Stop speaking and drop queued answers",
+			Type:        discordgo.ChatApplicationCommand,
+		},
+		{
+			Name:        "skip",
+			Description: "Skip the answer being spoken",
+			Type:        discordgo.ChatApplicationCommand,
+		},
+		{
+			Name:                     "settings",
+			Description:              "Show or adjust TARS settings for this server",
+			Type:                     discordgo.ChatApplicationCommand,
+			DefaultMemberPermissions: &manageGuild,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "humor",
+					Description: "Humor setting in percent",
+					MinValue:    &minSetting,
+					MaxValue:    100,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "honesty",
+					Description: "Honesty setting in percent",
+					MinValue:    &minSetting,
+					MaxValue:    100,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "verbosity",
+					Description: "Verbosity setting in percent",
+					MinValue:    &minSetting,
+					MaxValue:    100,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "memory_scope",
+					Description: "Which past conversations TARS may recall",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Each user in this server", Value: string(vectorstore.ScopeUser)},
+						{Name: "Everyone in the same channel", Value: string(vectorstore.ScopeChannel)},
+						{Name: "Everyone in this server", Value: string(vectorstore.ScopeGuild)},
+						{Name: "Each user across all servers", Value: string(vectorstore.ScopeGlobal)},
+					},
+				},
+			},
+		},
+		{
+			Name:                     "listen",
+			Description:              "Show or change when TARS answers in voice channels",
+			Type:                     discordgo.ChatApplicationCommand,
+			DefaultMemberPermissions: &manageGuild,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "Listening mode",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Only when addressed by wake word", Value: listenWakeWord},
+						{Name: "Answer everything said in the channel", Value: listenAlways},
+					},
+				},
+			},
+		},
+		{
+			Name:                     "voice",
+			Description:              "Show or change how TARS sounds in voice channels",
+			Type:                     discordgo.ChatApplicationCommand,
+			DefaultMemberPermissions: &manageGuild,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "show",
+					Description: "Show the current voice settings",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "set",
+					Description: "Change the voice settings",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "voice",
+							Description:  "Voice to speak with",
+							Autocomplete: true,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "model",
+							Description:  "Speech model",
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionNumber,
+							Name:        "speed",
+							Description: "Speaking speed, 1 is normal",
+							MinValue:    &minSpeed,
+							MaxValue:    4,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "format",
+							Description: "Audio format requested from the speech service",
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "WAV", Value: "wav"},
+								{Name: "Raw PCM", Value: "pcm"},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
-
-	// Register commands globally
-	registeredCommands, err := b.Session.ApplicationCommandBulkOverwrite(b.Session.State.User.ID, "", commands)
-	if err != nil {
-		return err
-	}
-
-	log.Printf(" Registered %d commands", len(registeredCommands))
-	for _, command := range registeredCommands {
-		log.Printf(" Command: %v", command)
-	}
-	return nil
 }
@@ -0,0 +1,12 @@
+package discord
+
+import "testing"
+
+func TestEveryCommandHasHandler(t *testing.T) {
+	b := &Bot{}
+	for _, command := range applicationCommands() {
+		if b.commandHandler(command.Name) == nil {
+			t.Errorf("command /%s is registered but never dispatched", command.Name)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// attachmentThreshold is the answer length above which it is sent as a
+	// markdown file instead of a wall of messages.
+	attachmentThreshold = 4 * maxMessageLength
+	attachmentName      = "answer.md"
+	codeFence           = "```"
+)
+
+// splitMessage breaks content into chunks of at most limit characters,
+// preferring paragraph boundaries, then line and word boundaries. A code
+// block cut in two is closed at the end of one chunk and reopened, with its
+// language, at the start of the next.
+func splitMessage(content string, limit int) []string {
+	if utf8.RuneCountInString(content) <= limit {
+		return []string{content}
+	}
+
+	s := &splitter{limit: limit}
+	fence := ""
+	for _, line := range strings.Split(content, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, codeFence) {
+			if fence == "" {
+				// Only the "```lang" token is reopened; an info string
+				// after it would eat into every following chunk.
+				fence = strings.Fields(trimmed)[0]
+			} else {
+				fence = ""
+			}
+		}
+
+		// Leave room to reopen and close a code block around the line
+		room := max(limit-utf8.RuneCountInString(fence)-len("\n\n"+codeFence), 1)
+		for _, piece := range wrapLine(line, room) {
+			s.add(piece, fence)
+		}
+	}
+
+	return s.finish()
+}
+
+// splitter accumulates lines, remembering which code block, if any, is open
+// after each so a chunk can be cut anywhere.
+type splitter struct {
+	limit  int
+	chunks []string
+	lines  []string
+	fences []string
+	// reopened is set when the pending chunk starts with a fence line
+	// carried over from the previous chunk.
+	reopened bool
+}
+
+func (s *splitter) add(line, fence string) {
+	s.lines = append(s.lines, line)
+	s.fences = append(s.fences, fence)
+
+	for len(s.lines) > s.firstCut() && s.size() > s.limit {
+		// Cut at the last paragraph break, else before the newest line
+		cut := len(s.lines) - 1
+		for i := len(s.lines) - 2; i >= s.firstCut(); i-- {
+			if strings.TrimSpace(s.lines[i]) == "" {
+				cut = i
+				break
+			}
+		}
+		s.emit(cut)
+	}
+}
+
+// firstCut returns the earliest index the pending chunk may be cut at. A
+// reopened chunk keeps its fence line and at least one line after it,
+// otherwise cutting would rebuild the same chunk forever.
+func (s *splitter) firstCut() int {
+	if s.reopened {
+		return 2
+	}
+	return 1
+}
+
+// emit turns the first n lines into a chunk, keeping the rest.
+func (s *splitter) emit(n int) {
+	open := s.fences[n-1]
+	chunk := strings.Join(s.lines[:n], "\n")
+	if open != "" {
+		chunk += "\n" + codeFence
+	}
+	s.chunks = append(s.chunks, chunk)
+
+	lines, fences := s.lines[n:], s.fences[n:]
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" && open == "" {
+		lines, fences = lines[1:], fences[1:]
+	}
+
+	s.lines, s.fences = nil, nil
+	s.reopened = open != ""
+	if open != "" {
+		s.lines = append(s.lines, open)
+		s.fences = append(s.fences, open)
+	}
+	s.lines = append(s.lines, lines...)
+	s.fences = append(s.fences, fences...)
+}
+
+// size returns the length of the pending chunk, including the fence needed
+// to close it.
+func (s *splitter) size() int {
+	size := len(s.lines) - 1
+	for _, line := range s.lines {
+		size += utf8.RuneCountInString(line)
+	}
+	if s.fences[len(s.fences)-1] != "" {
+		size += len("\n" + codeFence)
+	}
+	return size
+}
+
+func (s *splitter) finish() []string {
+	if len(s.lines) > 0 {
+		s.emit(len(s.lines))
+	}
+	return s.chunks
+}
+
+// wrapLine breaks a line longer than limit at spaces, or anywhere if it has none.
+func wrapLine(line string, limit int) []string {
+	var pieces []string
+	runes := []rune(line)
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		pieces = append(pieces, string(runes[:cut]))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	return append(pieces, string(runes))
+}
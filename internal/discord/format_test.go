@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		// fence is the opening line every chunk after the first must start with
+		fence string
+	}{
+		{
+			name:    "short",
+			content: "hello",
+			limit:   2000,
+		},
+		{
+			name:    "paragraphs",
+			content: strings.Repeat("word ", 300) + "\n\n" + strings.Repeat("more ", 300),
+			limit:   2000,
+		},
+		{
+			name:    "long line without spaces",
+			content: strings.Repeat("x", 5000),
+			limit:   2000,
+		},
+		{
+			name:    "code block",
+			content: "```go\n" + strings.Repeat("x := 1\n", 600) + "```",
+			limit:   2000,
+			fence:   "```go",
+		},
+		{
+			name:    "reopened code block with blank line",
+			content: "```go\n" + strings.Repeat("x := 1\n", 200) + "\n" + strings.Repeat("y := 2\n", 400) + "```",
+			limit:   2000,
+			fence:   "```go",
+		},
+		{
+			name:    "reopened code block with many blank lines",
+			content: "```\n" + strings.Repeat("a\n\n", 1500) + "```",
+			limit:   100,
+			fence:   "```",
+		},
+		{
+			name:    "fence with info string",
+			content: "```go title=\"main.go\" " + strings.Repeat("x", 60) + "\n" + strings.Repeat("x := 1\n", 50) + "```",
+			limit:   60,
+			fence:   "```go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitMessage(tt.content, tt.limit)
+			if len(chunks) == 0 {
+				t.Fatal("no chunks")
+			}
+
+			for i, chunk := range chunks {
+				if n := utf8.RuneCountInString(chunk); n > tt.limit {
+					t.Errorf("chunk %d has %d characters, limit %d", i, n, tt.limit)
+				}
+				if tt.fence == "" {
+					continue
+				}
+				if strings.Count(chunk, codeFence)%2 != 0 {
+					t.Errorf("chunk %d leaves a code block open:\n%s", i, chunk)
+				}
+				if i > 0 && !strings.HasPrefix(chunk, tt.fence+"\n") {
+					t.Errorf("chunk %d does not reopen %q:\n%s", i, tt.fence, chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageFenceLongerThanLimit(t *testing.T) {
+	content := "```" + strings.Repeat("g", 40) + "\n" + strings.Repeat("x := 1\n", 20) + "```"
+	if chunks := splitMessage(content, 30); len(chunks) < 2 {
+		t.Errorf("got %d chunks, want several", len(chunks))
+	}
+}
+
+func TestSplitMessageKeepsText(t *testing.T) {
+	content := strings.Repeat("one two three four\n", 100) + "\n" + strings.Repeat("five six\n", 100)
+	chunks := splitMessage(content, 200)
+
+	var got []string
+	for _, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 200 {
+			t.Errorf("chunk has %d characters, limit 200", n)
+		}
+		got = append(got, strings.Fields(chunk)...)
+	}
+	if want := strings.Fields(content); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Error("split lost or reordered words")
+	}
+}
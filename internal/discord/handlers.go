@@ -2,8 +2,14 @@ package discord
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"strconv"
 	"strings"
+	"tars-bot/internal/ai"
+	"tars-bot/internal/ai/vectorstore"
 	"tars-bot/internal/discord/voice"
 
 	"github.com/bwmarrin/discordgo"
@@ -12,107 +18,169 @@ import (
 func (b *Bot) interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	switch i.Type {
 	case discordgo.InteractionApplicationCommand:
+		if handler := b.commandHandler(i.ApplicationCommandData().Name); handler != nil {
+			handler(s, i)
+		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
 		switch i.ApplicationCommandData().Name {
-		case "chat":
-			b.handleChatCommand(s, i)
-		case "join":
-			b.handleJoinCommand(s, i)
-		case "leave":
-			b.handleLeaveCommand(s, i)
+		case "voice":
+			b.handleVoiceAutocomplete(s, i)
 		}
 	}
 }
 
-func (b *Bot) mentionHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Check if the bot was mentioned
-	if m.Mentions == nil || len(m.Mentions) == 0 {
+// commandHandler returns the handler for a slash command, or nil if the
+// command is unknown.
+func (b *Bot) commandHandler(name string) func(*discordgo.Session, *discordgo.InteractionCreate) {
+	switch name {
+	case "chat":
+		return b.handleChatCommand
+	case "talk":
+		return b.handleTalkCommand
+	case "join":
+		return b.handleJoinCommand
+	case "leave":
+		return b.handleLeaveCommand
+	case "stop", "skip":
+		return b.handlePlaybackCommand
+	case "settings":
+		return b.handleSettingsCommand
+	case "listen":
+		return b.handleListenCommand
+	case "voice":
+		return b.handleVoiceSettingsCommand
+	}
+	return nil
+}
+
+// messageHandler answers messages that mention the bot, and every message
+// sent in a conversation thread.
+func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	if session, ok := b.threadSession(s, m.ChannelID); ok {
+		content := stripMentions(m.Message)
+		if content != "" {
+			b.answerInSession(s, session, m.Author, content, m.ID)
+		}
 		return
 	}
 
+	// Otherwise only answer when mentioned
+	mentioned := false
 	for _, mention := range m.Mentions {
 		if mention.ID == s.State.User.ID {
-			// Remove the mention from the message
-			content := m.Content
-			for _, mention := range m.Mentions {
-				content = strings.ReplaceAll(content, mention.Mention(), "")
-			}
-			content = strings.TrimSpace(content)
-
-			if content == "" {
-				s.ChannelMessageSend(m.ChannelID, "You mentioned me! What would you like to talk about?")
-				return
-			}
+			mentioned = true
+		}
+	}
+	if !mentioned {
+		return
+	}
 
-			// Process the message with the AI agent
-			response, err := b.Agent.ProcessMessage(context.Background(), m.Author.ID, content)
-			if err != nil {
-				log.Printf("Error processing message: %v", err)
-				s.ChannelMessageSend(m.ChannelID, "Sorry, I had trouble processing that message.")
-				return
-			}
+	content := stripMentions(m.Message)
+	if content == "" {
+		s.ChannelMessageSend(m.ChannelID, "You mentioned me! What would you like to talk about?")
+		return
+	}
 
-			s.ChannelMessageSend(m.ChannelID, response)
+	// Continue the conversation in a thread of its own if configured
+	if b.Config.MentionThreads && m.GuildID != "" {
+		session, err := b.startSession(s, m.GuildID, m.ChannelID, m.ID, m.Author.ID, threadName(content, "Talk with TARS"))
+		if err == nil {
+			b.answerInSession(s, session, m.Author, content, "")
 			return
 		}
+		log.Printf("Error starting conversation thread: %v", err)
 	}
+
+	b.answer(s, m.ChannelID, ai.MessageContext{
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		UserID:    m.Author.ID,
+	}, content)
 }
 
 func (b *Bot) handleChatCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	options := i.ApplicationCommandData().Options
 	message := options[0].StringValue()
 
-	// Process the message with the AI agent
-	response, err := b.Agent.ProcessMessage(context.Background(), i.Member.User.ID, message)
+	// Acknowledge right away, then stream the answer into the deferred response
+	reply := deferReply(s, i, false)
+	mc := ai.MessageContext{
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+		UserID:    i.Member.User.ID,
+	}
+	if session, ok := b.threadSession(s, i.ChannelID); ok {
+		mc.UserName = i.Member.User.DisplayName()
+		mc.SessionID = session.SessionID
+		mc.History = b.threadHistory(s, i.ChannelID, "")
+	}
+
+	renderer := newStreamRenderer(newInteractionTarget(s, i.Interaction))
+	response, err := b.Agent.ProcessMessageStream(context.Background(), mc, message, renderer.Write)
 	if err != nil {
-		log.Printf("Error processing message: %v", err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Sorry, I had trouble processing that message.",
-			},
-		})
+		reply.Fail("Sorry, I had trouble processing that message.", err)
 		return
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: response,
-		},
-	})
+	renderer.Finish(response)
+}
+
+// handleTalkCommand starts a conversation thread, answering the optional
+// opening message in it.
+func (b *Bot) handleTalkCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		newReply(s, i, false).Fail("Conversation threads can only be started in a server", nil)
+		return
+	}
+	if _, ok := b.threadSession(s, i.ChannelID); ok {
+		newReply(s, i, false).Fail("We're already talking in this thread", nil)
+		return
+	}
+
+	var message string
+	if options := i.ApplicationCommandData().Options; len(options) > 0 {
+		message = options[0].StringValue()
+	}
+
+	reply := deferReply(s, i, false)
+	user := i.Member.User
+	session, err := b.startSession(s, i.GuildID, i.ChannelID, "", user.ID, threadName(message, "Talk with "+user.DisplayName()))
+	if err != nil {
+		reply.Fail("Error starting conversation thread", err)
+		return
+	}
+
+	err = s.ThreadMemberAdd(session.ChannelID, user.ID)
+	if err != nil {
+		log.Printf("Error adding user to thread: %v", err)
+	}
+
+	reply.Send(fmt.Sprintf("Let's talk in <#%s>, no need to mention me there.", session.ChannelID))
+
+	if message != "" {
+		b.answerInSession(s, session, user, message, "")
+	}
 }
 
 func (b *Bot) handleVoiceCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	// Implement voice command logic here
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: "Voice command received! (Not yet implemented)",
-		},
-	})
+	newReply(s, i, false).Send("Voice command received! (Not yet implemented)")
 }
 
 func (b *Bot) handleJoinCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	// Check if user is in a voice channel
 	voiceState, err := s.State.VoiceState(i.GuildID, i.Member.User.ID)
-	if err != nil {
-		log.Printf("Error getting voice state: %v", err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Error getting your voice state",
-			},
-		})
+	if err != nil && !errors.Is(err, discordgo.ErrStateNotFound) {
+		newReply(s, i, false).Fail("Error getting your voice state", err)
 		return
 	}
 
 	if voiceState == nil || voiceState.ChannelID == "" {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "You need to be in a voice channel to use this command",
-			},
-		})
+		newReply(s, i, false).Fail("You need to be in a voice channel to use this command", nil)
 		return
 	}
 
@@ -121,79 +189,244 @@ func (b *Bot) handleJoinCommand(s *discordgo.Session, i *discordgo.InteractionCr
 
 	// Check if bot is already in a voice channel
 	if _, exists := voice.GetActiveConnection(i.GuildID); exists {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "I'm already in a voice channel",
-			},
-		})
+		newReply(s, i, false).Fail("I'm already in a voice channel", nil)
 		return
 	}
 
+	// Joining takes a few seconds
+	reply := deferReply(s, i, false)
+
 	// Create new voice connection
-	vc, err := voice.NewVoiceConnection(s, i.GuildID, voiceState.ChannelID, b.Agent)
+	vc, err := voice.NewVoiceConnection(s, i.GuildID, voiceState.ChannelID, b.Agent, b.Config)
 	if err != nil {
-		log.Printf("Error creating voice connection: %v", err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Error creating voice connection",
-			},
-		})
+		reply.Fail("Error creating voice connection", err)
 		return
 	}
 
 	// Connect to voice channel
 	err = vc.Connect()
 	if err != nil {
-		log.Printf("Error connecting to voice channel: %v", err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Error connecting to voice channel: " + err.Error(),
-			},
-		})
+		reply.Fail("Error connecting to voice channel: "+err.Error(), err)
 		return
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: "Joined voice channel!",
-		},
-	})
+	// Post the conversation next to where /join was used
+	err = vc.EnableTranscript(i.ChannelID)
+	if err != nil {
+		log.Printf("Error enabling voice transcript: %v", err)
+	}
+
+	reply.Send("Joined voice channel!")
 }
 
 func (b *Bot) handleLeaveCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	// Check if bot is in a voice channel
 	conn, exists := voice.GetActiveConnection(i.GuildID)
 	if !exists {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "I'm not in a voice channel",
-			},
-		})
+		newReply(s, i, false).Fail("I'm not in a voice channel", nil)
 		return
 	}
 
-	// Disconnect from voice channel
+	// Disconnect from voice channel; closing the voice session takes a moment
+	reply := deferReply(s, i, false)
 	err := conn.Disconnect()
 	if err != nil {
-		log.Printf("Error disconnecting from voice channel: %v", err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Error disconnecting from voice channel",
-			},
-		})
+		reply.Fail("Error disconnecting from voice channel", err)
+		return
+	}
+
+	reply.Send("Left voice channel!")
+}
+
+// loadSettings defers the reply of a settings command and loads the guild's
+// settings, answering with an error if either is impossible.
+func (b *Bot) loadSettings(s *discordgo.Session, i *discordgo.InteractionCreate, what string) (*interactionReply, vectorstore.GuildSettings, bool) {
+	if i.GuildID == "" {
+		newReply(s, i, false).Fail(what+" can only be changed in a server", nil)
+		return nil, vectorstore.GuildSettings{}, false
+	}
+
+	reply := deferReply(s, i, false)
+	settings, err := b.Agent.Memory.GetGuildSettings(context.Background(), i.GuildID)
+	if err != nil {
+		reply.Fail("Error loading settings", err)
+		return nil, vectorstore.GuildSettings{}, false
+	}
+
+	return reply, settings, true
+}
+
+func (b *Bot) handleSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	reply, settings, ok := b.loadSettings(s, i, "Settings")
+	if !ok {
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	for _, option := range options {
+		switch option.Name {
+		case "humor":
+			settings.Humor = int(option.IntValue())
+		case "honesty":
+			settings.Honesty = int(option.IntValue())
+		case "verbosity":
+			settings.Verbosity = int(option.IntValue())
+		case "memory_scope":
+			settings.MemoryScope = vectorstore.MemoryScope(option.StringValue())
+		}
+	}
+
+	if len(options) > 0 {
+		err := b.Agent.Memory.SaveGuildSettings(context.Background(), settings)
+		if err != nil {
+			reply.Fail("Error saving settings", err)
+			return
+		}
+	}
+
+	reply.Send(fmt.Sprintf("Humor: %d%%. Honesty: %d%%. Verbosity: %d%%. Memory scope: %s.",
+		settings.Humor, settings.Honesty, settings.Verbosity, settings.MemoryScope))
+}
+
+func (b *Bot) handleListenCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	reply, settings, ok := b.loadSettings(s, i, "Listening mode")
+	if !ok {
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) > 0 {
+		settings.AlwaysListen = options[0].StringValue() == listenAlways
+		err := b.Agent.Memory.SaveGuildSettings(context.Background(), settings)
+		if err != nil {
+			reply.Fail("Error saving settings", err)
+			return
+		}
+	}
+
+	content := fmt.Sprintf("I only answer when addressed as %s.", strings.Join(b.Config.WakeWords, " or "))
+	if settings.AlwaysListen {
+		content = "I answer everything said in the voice channel."
+	}
+	reply.Send(content)
+}
+
+// handlePlaybackCommand interrupts voice playback: /stop drops everything
+// queued, /skip only the answer being spoken.
+func (b *Bot) handlePlaybackCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	reply := newReply(s, i, false)
+	conn, exists := voice.GetActiveConnection(i.GuildID)
+	var sender *voice.AudioSender
+	if exists {
+		sender = conn.Sender()
+	}
+	if sender == nil {
+		reply.Fail("I'm not in a voice channel", nil)
+		return
+	}
+
+	var interrupted bool
+	content := "Stopped."
+	if i.ApplicationCommandData().Name == "skip" {
+		interrupted = sender.Skip()
+		content = "Skipped."
+	} else {
+		interrupted = sender.Stop()
+	}
+	if !interrupted {
+		reply.Fail("I'm not saying anything.", nil)
 		return
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
+	reply.Send(content)
+}
+
+func (b *Bot) handleVoiceSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	reply, settings, ok := b.loadSettings(s, i, "Voice settings")
+	if !ok {
+		return
+	}
+
+	subcommand := i.ApplicationCommandData().Options[0]
+	if subcommand.Name == "set" && len(subcommand.Options) > 0 {
+		for _, option := range subcommand.Options {
+			switch option.Name {
+			case "voice":
+				settings.TTSVoice = option.StringValue()
+			case "model":
+				settings.TTSModel = option.StringValue()
+			case "speed":
+				settings.TTSSpeed = option.FloatValue()
+			case "format":
+				settings.TTSFormat = option.StringValue()
+				if !slices.Contains(b.Agent.TTS.Formats(), settings.TTSFormat) {
+					reply.Fail(fmt.Sprintf("The speech service can't produce %s audio. Choose one of: %s.",
+						settings.TTSFormat, strings.Join(b.Agent.TTS.Formats(), ", ")), nil)
+					return
+				}
+			}
+		}
+
+		err := b.Agent.Memory.SaveGuildSettings(context.Background(), settings)
+		if err != nil {
+			reply.Fail("Error saving settings", err)
+			return
+		}
+	}
+
+	speed := settings.TTSSpeed
+	if speed <= 0 {
+		speed = vectorstore.DefaultTTSSpeed
+	}
+	reply.Send(fmt.Sprintf("Voice: %s. Model: %s. Speed: %s. Format: %s.",
+		orDefault(settings.TTSVoice), orDefault(settings.TTSModel), strconv.FormatFloat(speed, 'f', -1, 64), orDefault(settings.TTSFormat)))
+}
+
+// handleVoiceAutocomplete suggests the voices and models matching what was
+// typed so far.
+func (b *Bot) handleVoiceAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, subcommand := range i.ApplicationCommandData().Options {
+		for _, option := range subcommand.Options {
+			if option.Focused {
+				focused = option
+			}
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	candidates := b.Agent.TTS.Voices()
+	if focused.Name == "model" {
+		candidates = b.Agent.TTS.Models()
+	}
+
+	typed := strings.ToLower(focused.StringValue())
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, candidate := range candidates {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		if strings.Contains(strings.ToLower(candidate), typed) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: candidate, Value: candidate})
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Left voice channel!",
+			Choices: choices,
 		},
 	})
+	if err != nil {
+		log.Printf("Error responding to autocomplete: %v", err)
+	}
+}
+
+func orDefault(value string) string {
+	if value == "" {
+		return "default"
+	}
+	return value
 }
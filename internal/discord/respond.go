@@ -0,0 +1,101 @@
+package discord
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// interactionReply answers a slash command. Commands that may take longer
+// than Discord's three second acknowledgement window defer first and deliver
+// the result by editing the deferred response. Errors are always shown only
+// to the invoking user.
+type interactionReply struct {
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+	ephemeral   bool
+	deferred    bool
+}
+
+// newReply prepares an immediate answer to a fast command.
+func newReply(s *discordgo.Session, i *discordgo.InteractionCreate, ephemeral bool) *interactionReply {
+	return &interactionReply{session: s, interaction: i.Interaction, ephemeral: ephemeral}
+}
+
+// deferReply acknowledges a slow command right away, showing the user that
+// TARS is thinking until Send or Fail delivers the outcome.
+func deferReply(s *discordgo.Session, i *discordgo.InteractionCreate, ephemeral bool) *interactionReply {
+	r := newReply(s, i, ephemeral)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: r.flags(),
+		},
+	})
+	if err != nil {
+		log.Printf("Error deferring interaction: %v", err)
+		return r
+	}
+
+	r.deferred = true
+	return r
+}
+
+// Send delivers the result of the command.
+func (r *interactionReply) Send(content string) {
+	var err error
+	if r.deferred {
+		_, err = r.session.InteractionResponseEdit(r.interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+	} else {
+		err = r.session.InteractionRespond(r.interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   r.flags(),
+			},
+		})
+	}
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+// Fail tells the user the command did not work, logging err if there is one.
+// A public deferred response is replaced by an ephemeral follow-up so the
+// error does not clutter the channel.
+func (r *interactionReply) Fail(content string, err error) {
+	if err != nil {
+		log.Printf("Error handling /%s: %v", r.interaction.ApplicationCommandData().Name, err)
+	}
+
+	if !r.deferred {
+		r.ephemeral = true
+		r.Send(content)
+		return
+	}
+	if r.ephemeral {
+		r.Send(content)
+		return
+	}
+
+	err = r.session.InteractionResponseDelete(r.interaction)
+	if err != nil {
+		log.Printf("Error deleting interaction response: %v", err)
+	}
+	_, err = r.session.FollowupMessageCreate(r.interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
+
+func (r *interactionReply) flags() discordgo.MessageFlags {
+	if r.ephemeral {
+		return discordgo.MessageFlagsEphemeral
+	}
+	return 0
+}
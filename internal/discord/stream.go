@@ -0,0 +1,181 @@
+package discord
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// streamEditInterval throttles message edits while a reply is streamed,
+	// keeping us well under Discord's per-message edit rate limit.
+	streamEditInterval = time.Second
+	maxMessageLength   = 2000
+	streamCursor       = " ▌"
+)
+
+// messageTarget is where a streamed answer is delivered: a message that is
+// edited as the answer grows, followed by extra messages when it is too long
+// for one.
+type messageTarget interface {
+	// Update replaces the content of the answer's first message.
+	Update(content string) error
+	// Send posts an additional message after the first.
+	Send(content string) error
+	// Attach replaces the first message with content and a file.
+	Attach(content string, file *discordgo.File) error
+}
+
+// streamRenderer accumulates streamed completion fragments and pushes the
+// partial answer to Discord at most once per interval.
+type streamRenderer struct {
+	target   messageTarget
+	interval time.Duration
+	content  strings.Builder
+	lastEdit time.Time
+}
+
+func newStreamRenderer(target messageTarget) *streamRenderer {
+	return &streamRenderer{
+		target:   target,
+		interval: streamEditInterval,
+	}
+}
+
+// Write appends a fragment of the answer. Failed edits are logged rather than
+// returned so a rate-limited edit does not abort the completion.
+func (r *streamRenderer) Write(delta string) error {
+	r.content.WriteString(delta)
+	if time.Since(r.lastEdit) < r.interval {
+		return nil
+	}
+
+	r.flush(r.content.String() + streamCursor)
+	return nil
+}
+
+// Finish replaces the partial answer with the final content. Answers over
+// the message limit continue in further messages, and very long ones are
+// attached as a markdown file instead.
+func (r *streamRenderer) Finish(content string) {
+	if utf8.RuneCountInString(content) > attachmentThreshold {
+		err := r.target.Attach("The answer is long, so here it is as a file.", &discordgo.File{
+			Name:        attachmentName,
+			ContentType: "text/markdown",
+			Reader:      strings.NewReader(content),
+		})
+		if err != nil {
+			log.Printf("Error attaching answer: %v", err)
+		}
+		return
+	}
+
+	chunks := splitMessage(content, maxMessageLength)
+	r.flush(chunks[0])
+	for _, chunk := range chunks[1:] {
+		err := r.target.Send(chunk)
+		if err != nil {
+			log.Printf("Error sending answer: %v", err)
+			return
+		}
+	}
+}
+
+func (r *streamRenderer) flush(content string) {
+	r.lastEdit = time.Now()
+	err := r.target.Update(clipMessage(content))
+	if err != nil {
+		log.Printf("Error updating streamed message: %v", err)
+	}
+}
+
+// interactionTarget edits the original response of a deferred interaction
+// and continues it with follow-up messages.
+type interactionTarget struct {
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+}
+
+func newInteractionTarget(s *discordgo.Session, interaction *discordgo.Interaction) *interactionTarget {
+	return &interactionTarget{session: s, interaction: interaction}
+}
+
+func (t *interactionTarget) Update(content string) error {
+	_, err := t.session.InteractionResponseEdit(t.interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	return err
+}
+
+func (t *interactionTarget) Send(content string) error {
+	_, err := t.session.FollowupMessageCreate(t.interaction, true, &discordgo.WebhookParams{
+		Content: content,
+	})
+	return err
+}
+
+func (t *interactionTarget) Attach(content string, file *discordgo.File) error {
+	_, err := t.session.InteractionResponseEdit(t.interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Files:   []*discordgo.File{file},
+	})
+	return err
+}
+
+// channelTarget sends a message on the first update and edits it afterwards.
+type channelTarget struct {
+	session   *discordgo.Session
+	channelID string
+	messageID string
+}
+
+func newChannelTarget(s *discordgo.Session, channelID string) *channelTarget {
+	return &channelTarget{session: s, channelID: channelID}
+}
+
+func (t *channelTarget) Update(content string) error {
+	if t.messageID == "" {
+		return t.send(&discordgo.MessageSend{Content: content})
+	}
+
+	_, err := t.session.ChannelMessageEdit(t.channelID, t.messageID, content)
+	return err
+}
+
+func (t *channelTarget) Send(content string) error {
+	_, err := t.session.ChannelMessageSend(t.channelID, content)
+	return err
+}
+
+func (t *channelTarget) Attach(content string, file *discordgo.File) error {
+	if t.messageID == "" {
+		return t.send(&discordgo.MessageSend{Content: content, Files: []*discordgo.File{file}})
+	}
+
+	edit := discordgo.NewMessageEdit(t.channelID, t.messageID).SetContent(content)
+	edit.Files = []*discordgo.File{file}
+	_, err := t.session.ChannelMessageEditComplex(edit)
+	return err
+}
+
+// send posts the first message, remembering it for later edits.
+func (t *channelTarget) send(data *discordgo.MessageSend) error {
+	msg, err := t.session.ChannelMessageSendComplex(t.channelID, data)
+	if err != nil {
+		return err
+	}
+	t.messageID = msg.ID
+	return nil
+}
+
+// clipMessage truncates content to Discord's message length limit.
+func clipMessage(content string) string {
+	runes := []rune(content)
+	if len(runes) <= maxMessageLength {
+		return content
+	}
+	return string(runes[:maxMessageLength-1]) + "…"
+}
@@ -0,0 +1,177 @@
+package discord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"tars-bot/internal/ai"
+	"tars-bot/internal/ai/vectorstore"
+	"tars-bot/pkg/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// threadArchiveMinutes is how long a conversation thread stays open without activity
+	threadArchiveMinutes = 1440
+	maxThreadName        = 100
+)
+
+// startSession creates a conversation thread in channelID, from messageID
+// when set, and binds it to a new session.
+func (b *Bot) startSession(s *discordgo.Session, guildID, channelID, messageID, userID, name string) (vectorstore.Session, error) {
+	var thread *discordgo.Channel
+	var err error
+	if messageID != "" {
+		thread, err = s.MessageThreadStart(channelID, messageID, name, threadArchiveMinutes)
+	} else {
+		thread, err = s.ThreadStart(channelID, name, discordgo.ChannelTypeGuildPublicThread, threadArchiveMinutes)
+	}
+	if err != nil {
+		return vectorstore.Session{}, fmt.Errorf("failed to create thread: %w", err)
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		return vectorstore.Session{}, err
+	}
+
+	session := vectorstore.Session{
+		ChannelID: thread.ID,
+		SessionID: sessionID,
+		GuildID:   guildID,
+		UserID:    userID,
+	}
+	err = b.Agent.Memory.SaveSession(context.Background(), session)
+	if err != nil {
+		return vectorstore.Session{}, err
+	}
+
+	return session, nil
+}
+
+// threadSession returns the session bound to channelID, reporting false for
+// channels that are not conversation threads.
+func (b *Bot) threadSession(s *discordgo.Session, channelID string) (vectorstore.Session, bool) {
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		channel, err = s.Channel(channelID)
+		if err != nil {
+			log.Printf("Error getting channel: %v", err)
+			return vectorstore.Session{}, false
+		}
+	}
+	if !channel.IsThread() {
+		return vectorstore.Session{}, false
+	}
+
+	session, ok, err := b.Agent.Memory.GetSession(context.Background(), channelID)
+	if err != nil {
+		log.Printf("Error loading session: %v", err)
+		return vectorstore.Session{}, false
+	}
+	return session, ok
+}
+
+// threadHistory returns the latest messages of a thread before beforeID,
+// oldest first, as chat history.
+func (b *Bot) threadHistory(s *discordgo.Session, channelID, beforeID string) []models.ChatMessage {
+	history := []models.ChatMessage{}
+	if b.Config.ThreadHistory <= 0 {
+		return history
+	}
+
+	messages, err := s.ChannelMessages(channelID, min(b.Config.ThreadHistory, 100), beforeID, "", "")
+	if err != nil {
+		log.Printf("Error loading thread history: %v", err)
+		return history
+	}
+
+	// Messages come newest first
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		if m.Type != discordgo.MessageTypeDefault && m.Type != discordgo.MessageTypeReply {
+			continue
+		}
+
+		content := stripMentions(m)
+		switch {
+		case content == "":
+		case m.Author.ID == s.State.User.ID:
+			history = append(history, models.ChatMessage{Role: models.RoleAssistant, Content: content})
+		case !m.Author.Bot:
+			history = append(history, models.ChatMessage{Role: models.RoleUser, Content: content, Name: m.Author.DisplayName()})
+		}
+	}
+	return history
+}
+
+// answerInSession answers message as a turn of session, with the thread's
+// messages before beforeID as context.
+func (b *Bot) answerInSession(s *discordgo.Session, session vectorstore.Session, user *discordgo.User, message, beforeID string) {
+	// A thread that was just started has no history yet
+	history := []models.ChatMessage{}
+	if beforeID != "" {
+		history = b.threadHistory(s, session.ChannelID, beforeID)
+	}
+
+	b.answer(s, session.ChannelID, ai.MessageContext{
+		GuildID:   session.GuildID,
+		ChannelID: session.ChannelID,
+		UserID:    user.ID,
+		UserName:  user.DisplayName(),
+		SessionID: session.SessionID,
+		History:   history,
+	}, message)
+}
+
+// answer streams the answer to message into a new message in channelID.
+func (b *Bot) answer(s *discordgo.Session, channelID string, mc ai.MessageContext, message string) {
+	s.ChannelTyping(channelID)
+	renderer := newStreamRenderer(newChannelTarget(s, channelID))
+	response, err := b.Agent.ProcessMessageStream(context.Background(), mc, message, renderer.Write)
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		renderer.Finish("Sorry, I had trouble processing that message.")
+		return
+	}
+
+	renderer.Finish(response)
+}
+
+// stripMentions removes user mentions from the content of m.
+func stripMentions(m *discordgo.Message) string {
+	content := m.Content
+	for _, mention := range m.Mentions {
+		content = strings.ReplaceAll(content, mention.Mention(), "")
+		content = strings.ReplaceAll(content, "<@!"+mention.ID+">", "")
+	}
+	return strings.TrimSpace(content)
+}
+
+// threadName derives a thread name from the message that started it.
+func threadName(message, fallback string) string {
+	name := strings.Join(strings.Fields(message), " ")
+	if name == "" {
+		return fallback
+	}
+
+	runes := []rune(name)
+	if len(runes) > maxThreadName {
+		return string(runes[:maxThreadName-1]) + "…"
+	}
+	return name
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,58 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tars-bot/internal/ai"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// registerTools adds the agent tools that need the Discord session.
+func (b *Bot) registerTools() error {
+	return b.Agent.Tools.Register(ai.Tool{
+		Name:        "guild_info",
+		Description: "Get information about the Discord server the conversation takes place in.",
+		Handler:     b.guildInfoTool,
+	})
+}
+
+func (b *Bot) guildInfoTool(ctx context.Context, inv ai.ToolInvocation) (string, error) {
+	if inv.GuildID == "" {
+		return "", fmt.Errorf("this conversation is not taking place in a server")
+	}
+
+	guild, err := b.Session.State.Guild(inv.GuildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get guild from state: %w", err)
+	}
+
+	created, _ := discordgo.SnowflakeTimestamp(guild.ID)
+	textChannels, voiceChannels := 0, 0
+	for _, channel := range guild.Channels {
+		switch channel.Type {
+		case discordgo.ChannelTypeGuildText:
+			textChannels++
+		case discordgo.ChannelTypeGuildVoice:
+			voiceChannels++
+		}
+	}
+
+	result, err := json.Marshal(map[string]interface{}{
+		"name":           guild.Name,
+		"description":    guild.Description,
+		"member_count":   guild.MemberCount,
+		"text_channels":  textChannels,
+		"voice_channels": voiceChannels,
+		"roles":          len(guild.Roles),
+		"created_at":     created.Format(time.RFC3339),
+		"premium_tier":   guild.PremiumTier,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
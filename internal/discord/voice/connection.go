@@ -5,8 +5,10 @@ import (
 	"errors"
 	"log"
 	"sync"
+	"sync/atomic"
 
 	"tars-bot/internal/ai"
+	"tars-bot/internal/config"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -21,15 +23,26 @@ type VoiceConnection struct {
 	GuildID         string
 	ChannelID       string
 	VoiceConnection *discordgo.VoiceConnection
-	AudioReceiver   *AudioReceiver
-	AudioSender     *AudioSender
-	Agent           *ai.AIAgent
-	Context         context.Context
-	Cancel          context.CancelFunc
-	Mutex           sync.Mutex
+	// AudioReceiver and AudioSender are replaced on reconnect; read them
+	// with Mutex held, or through Sender.
+	AudioReceiver *AudioReceiver
+	AudioSender   *AudioSender
+	Agent         *ai.AIAgent
+	Config        *config.Config
+	Context       context.Context
+	Cancel        context.CancelFunc
+	Mutex         sync.Mutex
+
+	// stopAudio stops the receiver and sender of the current voice session
+	stopAudio context.CancelFunc
+	// lastActivity is when someone last spoke or the bot last answered, in
+	// Unix nanoseconds
+	lastActivity atomic.Int64
+	// transcript receives what was said, if enabled
+	transcript *transcriptSink
 }
 
-func NewVoiceConnection(s *discordgo.Session, guildID, channelID string, agent *ai.AIAgent) (*VoiceConnection, error) {
+func NewVoiceConnection(s *discordgo.Session, guildID, channelID string, agent *ai.AIAgent, cfg *config.Config) (*VoiceConnection, error) {
 	connectionsMutex.Lock()
 	defer connectionsMutex.Unlock()
 
@@ -44,6 +57,7 @@ func NewVoiceConnection(s *discordgo.Session, guildID, channelID string, agent *
 		GuildID:   guildID,
 		ChannelID: channelID,
 		Agent:     agent,
+		Config:    cfg,
 		Context:   ctx,
 		Cancel:    cancel,
 	}
@@ -67,21 +81,48 @@ func (vc *VoiceConnection) Connect() error {
 	}
 	vc.VoiceConnection = voiceConn
 
-	// Initialize audio receiver
-	vc.AudioReceiver = NewAudioReceiver(vc)
-	go vc.AudioReceiver.Start()
-
-	// Initialize audio sender
-	vc.AudioSender, err = NewAudioSender(vc)
+	err = vc.startAudio()
 	if err != nil {
 		vc.VoiceConnection.Disconnect()
 		return err
 	}
-	go vc.AudioSender.Start()
+
+	vc.touch()
+	go vc.watch()
 
 	return nil
 }
 
+// startAudio starts a fresh receiver and sender on the current voice session.
+func (vc *VoiceConnection) startAudio() error {
+	sender, err := NewAudioSender(vc)
+	if err != nil {
+		return err
+	}
+	receiver, err := NewAudioReceiver(vc, sender)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(vc.Context)
+	vc.AudioSender, vc.AudioReceiver, vc.stopAudio = sender, receiver, cancel
+
+	// The sender must exist before the receiver can interrupt it on barge-in
+	go sender.Start(ctx)
+	go receiver.Start(ctx)
+
+	return nil
+}
+
+// Sender returns the audio sender of the current voice session, or nil
+// before the first connection.
+func (vc *VoiceConnection) Sender() *AudioSender {
+	vc.Mutex.Lock()
+	defer vc.Mutex.Unlock()
+
+	return vc.AudioSender
+}
+
 func (vc *VoiceConnection) Disconnect() error {
 	vc.Mutex.Lock()
 	defer vc.Mutex.Unlock()
@@ -2,79 +2,401 @@
 package voice
 
 import (
-	"bytes"
+	"context"
 	"log"
+	"strings"
 	"sync"
+	"time"
+
+	"tars-bot/internal/ai"
+	"tars-bot/internal/ai/vectorstore"
+	"tars-bot/internal/audio"
+	"tars-bot/pkg/models"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/hraban/opus"
+)
+
+const (
+	// Discord voice is always 48kHz stereo Opus
+	sampleRate = 48000
+	channels   = 2
+	// maxFrameSamples is the per-channel size of the longest (120ms) Opus packet
+	maxFrameSamples = 5760
+	// endpointTick is how often speakers are checked for having gone quiet
+	endpointTick = 100 * time.Millisecond
 )
 
 type AudioReceiver struct {
 	Connection *VoiceConnection
-	Buffer     bytes.Buffer
 	Mutex      sync.Mutex
+
+	// voiceConn is the voice session the receiver was started on, and
+	// sender the sender started with it
+	voiceConn *discordgo.VoiceConnection
+	sender    *AudioSender
+	// streams holds one speaker stream per RTP SSRC
+	streams map[uint32]*speakerStream
+	// users maps SSRCs to Discord user IDs, learned from speaking updates
+	users map[uint32]string
+	// names caches speaker display names by user ID
+	names map[string]string
+
+	wakeWords *wakeWordMatcher
+	// followUps holds, per user, until when they may talk without the wake word
+	followUps map[string]time.Time
+}
+
+// speakerStream is the audio of a single speaker. Each SSRC needs its own
+// decoder since Opus decoding is stateful.
+type speakerStream struct {
+	ssrc       uint32
+	decoder    *opus.Decoder
+	endpointer *endpointer
 }
 
-func NewAudioReceiver(vc *VoiceConnection) *AudioReceiver {
+// utterance is a complete stretch of speech from one user.
+type utterance struct {
+	userID  string
+	packets [][]byte
+	pcm     []int16
+}
+
+// NewAudioReceiver creates a receiver for the current voice session of vc,
+// answering through sender. The caller must hold vc.Mutex.
+func NewAudioReceiver(vc *VoiceConnection, sender *AudioSender) (*AudioReceiver, error) {
 	return &AudioReceiver{
 		Connection: vc,
-	}
+		voiceConn:  vc.VoiceConnection,
+		sender:     sender,
+		streams:    make(map[uint32]*speakerStream),
+		users:      make(map[uint32]string),
+		names:      make(map[string]string),
+		wakeWords:  newWakeWordMatcher(vc.Config.WakeWords, vc.Config.WakeWordTolerance),
+		followUps:  make(map[string]time.Time),
+	}, nil
 }
 
-func (ar *AudioReceiver) Start() {
+// Start reads received Opus packets until ctx is cancelled.
+func (ar *AudioReceiver) Start(ctx context.Context) {
 	log.Println("Starting audio receiver")
 
-	opusBuffer := make([]byte, 0, 20*960) // Buffer for 20ms chunks at 48kHz
-	opusChan := make(chan *discordgo.Packet, 10)
+	voiceConn := ar.voiceConn
+
+	// Learn which user speaks on which SSRC
+	voiceConn.AddHandler(ar.speakingUpdate)
+
+	// discordgo delivers packets on the channel it created when connecting
+	// and keeps it across its own reconnects
+	opusChan := voiceConn.OpusRecv
+	if opusChan == nil {
+		log.Println("Voice connection is not receiving audio")
+		return
+	}
+	voiceConn.Speaking(true)
+	defer voiceConn.Speaking(false)
 
-	// Enable receiving Opus packets
-	ar.Connection.VoiceConnection.OpusRecv = opusChan
-	ar.Connection.VoiceConnection.Speaking(true)
-	defer ar.Connection.VoiceConnection.Speaking(false)
+	ticker := time.NewTicker(endpointTick)
+	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ar.Connection.Context.Done():
+		case <-ctx.Done():
 			return
+		case now := <-ticker.C:
+			// Speakers who stopped transmitting altogether
+			ar.Mutex.Lock()
+			for _, stream := range ar.streams {
+				if frames := stream.endpointer.Tick(now); frames != nil {
+					ar.dispatch(stream.ssrc, frames)
+				}
+			}
+			ar.Mutex.Unlock()
 		case packet := <-opusChan:
 			if packet == nil {
 				continue
 			}
-			ar.Mutex.Lock()
-			opusBuffer = append(opusBuffer, packet.Opus...)
-			ar.Mutex.Unlock()
 
-			// Process when we have enough data (20ms chunks)
-			if len(opusBuffer) >= 960 {
-				ar.processAudioChunk(opusBuffer)
-				opusBuffer = opusBuffer[:0] // Reset buffer
+			stream, err := ar.stream(packet.SSRC)
+			if err != nil {
+				log.Printf("Error creating decoder for SSRC %d: %v", packet.SSRC, err)
+				continue
+			}
+
+			f, err := stream.decode(packet)
+			if err != nil {
+				log.Printf("Error decoding opus packet from SSRC %d: %v", packet.SSRC, err)
+				continue
+			}
+
+			if frames := stream.endpointer.Push(f, time.Now()); frames != nil {
+				ar.Mutex.Lock()
+				ar.dispatch(stream.ssrc, frames)
+				ar.Mutex.Unlock()
 			}
 		}
 	}
 }
 
-func (ar *AudioReceiver) processAudioChunk(opusData []byte) {
+func (ar *AudioReceiver) speakingUpdate(vc *discordgo.VoiceConnection, vs *discordgo.VoiceSpeakingUpdate) {
+	ar.Mutex.Lock()
+	defer ar.Mutex.Unlock()
+
+	ar.users[uint32(vs.SSRC)] = vs.UserID
+}
+
+// stream returns the speaker stream of ssrc, creating it on first use.
+func (ar *AudioReceiver) stream(ssrc uint32) (*speakerStream, error) {
+	ar.Mutex.Lock()
+	defer ar.Mutex.Unlock()
+
+	if stream, exists := ar.streams[ssrc]; exists {
+		return stream, nil
+	}
+
+	decoder, err := opus.NewDecoder(sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &speakerStream{
+		ssrc:       ssrc,
+		decoder:    decoder,
+		endpointer: newEndpointer(ar.Connection.Config, ar.bargeIn),
+	}
+	ar.streams[ssrc] = stream
+	return stream, nil
+}
+
+// dispatch hands a finished utterance to the transcription pipeline. The
+// caller must hold ar.Mutex. Utterances are processed in the background so
+// one speaker's round trip to STT and the LLM does not stall the others.
+func (ar *AudioReceiver) dispatch(ssrc uint32, frames []frame) {
+	u := &utterance{userID: ar.users[ssrc]}
+	if u.userID == "" {
+		log.Printf("Dropping audio from unknown speaker on SSRC %d", ssrc)
+		return
+	}
+	ar.Connection.touch()
+
+	for _, f := range frames {
+		u.packets = append(u.packets, f.opus)
+		u.pcm = append(u.pcm, f.pcm...)
+	}
+
+	go ar.processAudioChunk(u)
+}
+
+// decode decodes a received packet into a frame.
+func (s *speakerStream) decode(packet *discordgo.Packet) (frame, error) {
+	pcm := make([]int16, maxFrameSamples*channels)
+	n, err := s.decoder.Decode(packet.Opus, pcm)
+	if err != nil {
+		return frame{}, err
+	}
+
+	return frame{opus: packet.Opus, pcm: pcm[:n*channels]}, nil
+}
+
+// speakerName returns the display name of userID in the guild.
+func (ar *AudioReceiver) speakerName(userID string) string {
+	ar.Mutex.Lock()
+	name, cached := ar.names[userID]
+	ar.Mutex.Unlock()
+	if cached {
+		return name
+	}
+
+	session := ar.Connection.Session
+	member, err := session.State.Member(ar.Connection.GuildID, userID)
+	if err != nil {
+		member, err = session.GuildMember(ar.Connection.GuildID, userID)
+	}
+	if err != nil {
+		log.Printf("Error looking up speaker %s: %v", userID, err)
+		return userID
+	}
+
+	name = member.DisplayName()
+	ar.Mutex.Lock()
+	ar.names[userID] = name
+	ar.Mutex.Unlock()
+	return name
+}
+
+// encode packages an utterance in the container configured for STT and
+// returns it with a matching file name.
+func (ar *AudioReceiver) encode(u *utterance) ([]byte, string, error) {
+	if ar.Connection.Config.STTAudioFormat == "wav" {
+		// Speech recognition gains nothing from stereo; halve the upload
+		return audio.EncodeWAV(audio.DownmixStereo(u.pcm), sampleRate, 1), "audio.wav", nil
+	}
+
+	data, err := audio.EncodeOggOpus(u.packets, channels)
+	return data, "audio.ogg", err
+}
+
+func (ar *AudioReceiver) processAudioChunk(u *utterance) {
+	audioData, filename, err := ar.encode(u)
+	if err != nil {
+		log.Printf("Error encoding audio: %v", err)
+		return
+	}
+
 	// Send to STT
-	text, err := ar.Connection.Agent.STT.Transcribe(ar.Connection.Context, opusData)
+	cfg := ar.Connection.Config
+	transcription, err := ar.Connection.Agent.STT.Transcribe(ar.Connection.Context, audioData, filename, models.STTOptions{
+		Language:       cfg.STTLanguage,
+		Prompt:         ar.sttPrompt(),
+		ResponseFormat: cfg.STTResponseFormat,
+	})
 	if err != nil {
 		log.Printf("Error transcribing audio: %v", err)
 		return
 	}
 
+	text := strings.TrimSpace(transcription.Text)
 	if text == "" {
 		log.Println("Empty transcription received")
 		return
 	}
 
-	log.Printf("Transcribed text: %s", text)
+	name := ar.speakerName(u.userID)
+	if confidence := transcription.Confidence(); confidence < cfg.STTMinConfidence {
+		log.Printf("Ignoring low-confidence (%.2f) transcription from %s: %s", confidence, name, text)
+		return
+	}
+	log.Printf("Transcribed text from %s: %s", name, text)
+	ar.Connection.addTranscript(name, text)
 
-	// Process with AI agent
-	response, err := ar.Connection.Agent.ProcessMessage(ar.Connection.Context, "voice-user", text)
+	settings, err := ar.Connection.Agent.Memory.GetGuildSettings(ar.Connection.Context, ar.Connection.GuildID)
+	if err != nil {
+		log.Printf("Error loading guild settings: %v", err)
+		settings = vectorstore.DefaultGuildSettings(ar.Connection.GuildID)
+	}
+
+	text, addressed := ar.addressedText(settings, u.userID, text)
+	if !addressed {
+		log.Printf("Ignoring utterance from %s without wake word", name)
+		return
+	}
+	if text == "" {
+		// Only the wake word was said; the question follows in the window
+		return
+	}
+
+	// Process with AI agent, speaking each sentence as soon as it is complete
+	sender := ar.sender
+	r := newReply(ar.Connection.Context, models.TTSOptions{
+		Model:  settings.TTSModel,
+		Voice:  settings.TTSVoice,
+		Speed:  settings.TTSSpeed,
+		Format: settings.TTSFormat,
+	})
+	splitter := &sentenceSplitter{}
+	turn, err := ar.Connection.Agent.GenerateStream(ar.Connection.Context, ai.MessageContext{
+		GuildID:   ar.Connection.GuildID,
+		ChannelID: ar.Connection.ChannelID,
+		UserID:    u.userID,
+		UserName:  name,
+	}, text, func(delta string) error {
+		for _, sentence := range splitter.Write(delta) {
+			sender.QueueResponse(r, sentence)
+		}
+		return nil
+	})
 	if err != nil {
 		log.Printf("Error processing message: %v", err)
+		sender.FinishResponse(r)
 		return
 	}
 
-	// Send response to TTS
-	ar.Connection.AudioSender.QueueResponse(response)
+	// Send the remaining text to TTS
+	if rest := splitter.Flush(); rest != "" {
+		sender.QueueResponse(r, rest)
+	}
+	sender.FinishResponse(r)
+
+	// Remember the answer as far as it was heard
+	<-r.done
+	if spoken, interrupted := r.Result(); interrupted {
+		log.Printf("Answer to %s was interrupted", name)
+		turn.Interrupt(spoken)
+	}
+	ar.Connection.Agent.Remember(ar.Connection.Context, turn)
+	ar.Connection.addTranscript(ar.Connection.Session.State.User.Username, turn.Response)
+
+	ar.openFollowUp(u.userID)
+}
+
+// sttPrompt biases transcription towards the configured vocabulary, the
+// wake words and the names of the people in the channel, which speech
+// recognition otherwise tends to mangle.
+func (ar *AudioReceiver) sttPrompt() string {
+	cfg := ar.Connection.Config
+	words := append([]string{}, cfg.WakeWords...)
+
+	state := ar.Connection.Session.State
+	if guild, err := state.Guild(ar.Connection.GuildID); err == nil {
+		state.RLock()
+		for _, vs := range guild.VoiceStates {
+			if vs.ChannelID == ar.Connection.ChannelID && vs.Member != nil {
+				words = append(words, vs.Member.DisplayName())
+			}
+		}
+		state.RUnlock()
+	}
+
+	prompt := strings.Join(words, ", ")
+	if cfg.STTPrompt != "" {
+		prompt = cfg.STTPrompt + " " + prompt
+	}
+	return clipPrompt(prompt)
+}
+
+// clipPrompt keeps the prompt within what the transcription model reads;
+// Whisper only looks at the last 224 tokens.
+func clipPrompt(prompt string) string {
+	const maxPromptLength = 800
+	runes := []rune(prompt)
+	if len(runes) <= maxPromptLength {
+		return prompt
+	}
+	return string(runes[:maxPromptLength])
+}
+
+// bargeIn stops the bot talking when a user starts speaking over it.
+func (ar *AudioReceiver) bargeIn() {
+	if ar.sender.Speaking() {
+		log.Println("User started speaking, stopping playback")
+		ar.sender.Stop()
+	}
+}
+
+// addressedText decides whether an utterance was meant for the bot and
+// returns the part to answer. Unless the guild enabled always-listen mode, an
+// utterance must start with a wake word or come from a user still inside the
+// follow-up window of their last exchange.
+func (ar *AudioReceiver) addressedText(settings vectorstore.GuildSettings, userID, text string) (string, bool) {
+	if settings.AlwaysListen {
+		return text, true
+	}
+
+	if rest, ok := ar.wakeWords.Match(text); ok {
+		ar.openFollowUp(userID)
+		return rest, true
+	}
+
+	ar.Mutex.Lock()
+	defer ar.Mutex.Unlock()
+	return text, time.Now().Before(ar.followUps[userID])
+}
+
+// openFollowUp lets userID keep talking to the bot without the wake word for
+// the configured window.
+func (ar *AudioReceiver) openFollowUp(userID string) {
+	ar.Mutex.Lock()
+	defer ar.Mutex.Unlock()
+
+	ar.followUps[userID] = time.Now().Add(ar.Connection.Config.FollowUpWindow)
 }
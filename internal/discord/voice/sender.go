@@ -1,24 +1,132 @@
 package voice
 
 import (
+	"context"
 	"encoding/binary"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
-	"time"
 
+	"tars-bot/internal/audio"
+	"tars-bot/pkg/models"
+
+	"github.com/bwmarrin/discordgo"
 	"github.com/hraban/opus"
 )
 
+const (
+	// frameSamples is the per-channel length of a 20ms frame at 48kHz
+	frameSamples = 960
+	// maxPacketSize is the largest Opus packet libopus recommends allocating for
+	maxPacketSize = 4000
+	// pcmSampleRate is the rate of raw PCM speech, which carries no header
+	pcmSampleRate = 24000
+)
+
 type AudioSender struct {
 	Connection *VoiceConnection
-	Queue      chan string
+	Queue      chan speech
 	Mutex      sync.Mutex
 	Encoder    *opus.Encoder
+
+	// voiceConn is the voice session the sender was started on. A reconnect
+	// replaces Connection.VoiceConnection along with the sender.
+	voiceConn *discordgo.VoiceConnection
+	// replies are the answers queued or playing that have not finished
+	replies map[*reply]struct{}
+	// current is the answer being played
+	current *reply
+	// synthesizing bounds the number of sentences synthesized at once
+	synthesizing chan struct{}
+	// stopped is closed, and closed set, once Start has returned
+	stopped chan struct{}
+	closed  bool
+}
+
+// speech is a queued sentence of a reply. An empty text marks the end of
+// the reply.
+type speech struct {
+	reply *reply
+	text  string
+}
+
+// synthesis is a sentence being turned into audio. pcm holds 48kHz stereo
+// samples once ready is closed, unless err is set.
+type synthesis struct {
+	speech
+	ready chan struct{}
+	pcm   []int16
+	err   error
+}
+
+// reply is one answer, spoken sentence by sentence. It records what was
+// actually played so an interrupted answer can be remembered as heard.
+type reply struct {
+	// tts selects the voice the reply is spoken in
+	tts models.TTSOptions
+	// ctx is cancelled when the reply is interrupted, stopping its synthesis
+	// and playback
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex       sync.Mutex
+	spoken      []string
+	interrupted bool
+	done        chan struct{}
+	once        sync.Once
+}
+
+func newReply(ctx context.Context, tts models.TTSOptions) *reply {
+	ctx, cancel := context.WithCancel(ctx)
+	return &reply{tts: tts, ctx: ctx, cancel: cancel, done: make(chan struct{})}
 }
 
+func (r *reply) addSpoken(text string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if text != "" {
+		r.spoken = append(r.spoken, text)
+	}
+}
+
+func (r *reply) interrupt() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.interrupted = true
+	r.cancel()
+}
+
+func (r *reply) isInterrupted() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.interrupted
+}
+
+// finish marks the reply as done playing, whether completely or not.
+func (r *reply) finish() {
+	r.once.Do(func() {
+		r.cancel()
+		close(r.done)
+	})
+}
+
+// Result returns the text that was played and whether playback was cut off.
+func (r *reply) Result() (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return strings.Join(r.spoken, " "), r.interrupted
+}
+
+// NewAudioSender creates a sender for the current voice session of vc. The
+// caller must hold vc.Mutex.
 func NewAudioSender(vc *VoiceConnection) (*AudioSender, error) {
 	// Initialize Opus encoder with proper settings for Discord
-	encoder, err := opus.NewEncoder(48000, 2, opus.AppVoIP)
+	encoder, err := opus.NewEncoder(sampleRate, channels, opus.AppVoIP)
 	if err != nil {
 		return nil, err
 	}
@@ -28,83 +136,280 @@ func NewAudioSender(vc *VoiceConnection) (*AudioSender, error) {
 
 	return &AudioSender{
 		Connection: vc,
-		Queue:      make(chan string, 10),
+		Queue:      make(chan speech, 64),
 		Encoder:    encoder,
+		voiceConn:  vc.VoiceConnection,
+		replies:    make(map[*reply]struct{}),
+
+		synthesizing: make(chan struct{}, max(vc.Config.TTSParallelism, 1)),
+		stopped:      make(chan struct{}),
 	}, nil
 }
 
-func (as *AudioSender) Start() {
+// Start synthesizes queued sentences ahead of playback, several at a time,
+// while a separate goroutine plays them in order as each becomes ready.
+// It runs until ctx is cancelled, abandoning whatever is left to play.
+func (as *AudioSender) Start(ctx context.Context) {
 	log.Println("Starting audio sender")
+	defer as.abandon()
+
+	playback := make(chan *synthesis, cap(as.Queue))
+	go as.play(ctx, playback)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-as.Queue:
+			syn := &synthesis{speech: item, ready: make(chan struct{})}
+			if item.text == "" || item.reply.isInterrupted() {
+				close(syn.ready)
+			} else {
+				select {
+				case as.synthesizing <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				go as.synthesize(syn)
+			}
+
+			select {
+			case playback <- syn:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// synthesize generates the audio of a sentence.
+func (as *AudioSender) synthesize(syn *synthesis) {
+	defer close(syn.ready)
+	defer func() { <-as.synthesizing }()
+
+	audioData, err := as.Connection.Agent.TTS.Generate(syn.reply.ctx, syn.text, syn.reply.tts)
+	if err != nil {
+		syn.err = err
+		return
+	}
+
+	pcm, rate, ch, err := decodeSpeech(audioData, syn.reply.tts.Format)
+	if err != nil {
+		syn.err = err
+		return
+	}
+	syn.pcm = audio.Resample(audio.ToStereo(pcm, ch), channels, rate, sampleRate)
+}
+
+// decodeSpeech returns the samples of synthesized speech in format.
+func decodeSpeech(data []byte, format string) ([]int16, int, int, error) {
+	switch format {
+	case "", "wav":
+		return audio.DecodeWAV(data)
+	case "pcm":
+		pcm := make([]int16, len(data)/2)
+		for i := range pcm {
+			pcm[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		return pcm, pcmSampleRate, 1, nil
+	default:
+		return nil, 0, 0, fmt.Errorf("cannot play %s speech", format)
+	}
+}
 
+// play plays synthesized sentences in queue order.
+func (as *AudioSender) play(ctx context.Context, playback <-chan *synthesis) {
 	for {
 		select {
-		case <-as.Connection.Context.Done():
+		case <-ctx.Done():
 			return
-		case text := <-as.Queue:
-			as.processText(text)
+		case syn := <-playback:
+			if syn.text == "" {
+				as.endReply(syn.reply)
+				continue
+			}
+
+			select {
+			case <-syn.ready:
+			case <-ctx.Done():
+				return
+			}
+
+			if syn.reply.isInterrupted() {
+				continue
+			}
+			if syn.err != nil {
+				log.Printf("Error generating TTS: %v", syn.err)
+				continue
+			}
+			as.processText(syn)
 		}
 	}
 }
 
-func (as *AudioSender) QueueResponse(text string) {
+// QueueResponse queues a sentence of r for playback.
+func (as *AudioSender) QueueResponse(r *reply, text string) {
+	as.Mutex.Lock()
+	if as.closed {
+		as.Mutex.Unlock()
+		return
+	}
+	as.replies[r] = struct{}{}
+	as.Mutex.Unlock()
+
 	select {
-	case as.Queue <- text:
+	case as.Queue <- speech{reply: r, text: text}:
 	default:
 		log.Println("Audio sender queue full, dropping message")
 	}
 }
 
-func (as *AudioSender) processText(text string) {
+// FinishResponse marks the end of r once all its sentences are queued. The
+// reply's done channel is closed when playback reaches it, or right away if
+// the sender has stopped.
+func (as *AudioSender) FinishResponse(r *reply) {
+	as.Mutex.Lock()
+	if as.closed {
+		as.Mutex.Unlock()
+		r.finish()
+		return
+	}
+	// Registered so abandon finishes it if the sender stops before playback
+	// reaches the end marker
+	as.replies[r] = struct{}{}
+	as.Mutex.Unlock()
+
+	select {
+	case as.Queue <- speech{reply: r}:
+	case <-as.stopped:
+		r.finish()
+	}
+}
+
+// abandon interrupts and finishes every reply once the sender stops, so
+// nobody waits on playback that will never happen.
+func (as *AudioSender) abandon() {
 	as.Mutex.Lock()
 	defer as.Mutex.Unlock()
 
-	// Generate audio from text
-	audioData, err := as.Connection.Agent.TTS.Generate(as.Connection.Context, text)
-	if err != nil {
-		log.Printf("Error generating TTS: %v", err)
-		return
+	as.closed = true
+	close(as.stopped)
+	for r := range as.replies {
+		r.interrupt()
+		r.finish()
+		delete(as.replies, r)
 	}
+}
 
-	// Convert to Opus
-	opusData, err := as.encodeToOpus(audioData)
-	if err != nil {
-		log.Printf("Error encoding to Opus: %v", err)
-		return
+// Speaking reports whether an answer is being played or waiting to be.
+func (as *AudioSender) Speaking() bool {
+	as.Mutex.Lock()
+	defer as.Mutex.Unlock()
+
+	return len(as.replies) > 0
+}
+
+// Stop cuts off the current answer and discards everything queued. It
+// reports whether there was anything to stop.
+func (as *AudioSender) Stop() bool {
+	as.Mutex.Lock()
+	stopped := len(as.replies) > 0
+	for r := range as.replies {
+		r.interrupt()
 	}
+	as.Mutex.Unlock()
 
-	// Send audio in chunks
-	chunkSize := 960 // 20ms chunks at 48kHz
-	for i := 0; i < len(opusData); i += chunkSize {
-		end := i + chunkSize
-		if end > len(opusData) {
-			end = len(opusData)
+	// Clear the queue, still letting replies finish
+	for {
+		select {
+		case item := <-as.Queue:
+			if item.text == "" {
+				as.endReply(item.reply)
+			}
+		default:
+			return stopped
 		}
+	}
+}
 
-		chunk := opusData[i:end]
-		as.Connection.VoiceConnection.Speaking(true)
-		as.Connection.VoiceConnection.OpusSend <- chunk
-		time.Sleep(20 * time.Millisecond) // Simulate real-time playback
+// Skip cuts off the answer being played and moves on to the next one. It
+// reports whether there was anything to skip.
+func (as *AudioSender) Skip() bool {
+	as.Mutex.Lock()
+	defer as.Mutex.Unlock()
+
+	if as.current == nil {
+		return false
 	}
+	as.current.interrupt()
+	return true
+}
+
+func (as *AudioSender) endReply(r *reply) {
+	as.Mutex.Lock()
+	delete(as.replies, r)
+	as.Mutex.Unlock()
 
-	as.Connection.VoiceConnection.Speaking(false)
+	r.finish()
 }
 
-func (as *AudioSender) encodeToOpus(pcmData []byte) ([]byte, error) {
-	// Convert bytes to int16 samples
-	samples := make([]int16, len(pcmData)/2)
-	for i := 0; i < len(samples); i++ {
-		samples[i] = int16(binary.LittleEndian.Uint16(pcmData[i*2:]))
+// processText plays a synthesized sentence, encoding one Opus packet per
+// 20ms frame and padding the final frame with silence. discordgo paces the
+// UDP writes.
+func (as *AudioSender) processText(syn *synthesis) {
+	as.Connection.touch()
+
+	as.Mutex.Lock()
+	as.current = syn.reply
+	as.Mutex.Unlock()
+
+	defer func() {
+		as.Mutex.Lock()
+		as.current = nil
+		as.Mutex.Unlock()
+	}()
+
+	pcm := syn.pcm
+	frameLen := frameSamples * channels
+	if rest := len(pcm) % frameLen; rest != 0 {
+		pcm = append(pcm, make([]int16, frameLen-rest)...)
 	}
+	frames := len(pcm) / frameLen
 
-	// Create buffer for Opus data
-	opusData := make([]byte, len(samples)*2) // Enough space for encoded data
+	vc := as.voiceConn
+	vc.Speaking(true)
+	defer vc.Speaking(false)
 
-	// Encode to Opus
-	frameSize := len(samples) / 2 // 20ms frame size
-	encoded, err := as.Encoder.Encode(samples[:frameSize], opusData)
-	if err != nil {
-		return nil, err
+	buf := make([]byte, maxPacketSize)
+	for i := 0; i < frames; i++ {
+		n, err := as.Encoder.Encode(pcm[i*frameLen:(i+1)*frameLen], buf)
+		if err != nil {
+			log.Printf("Error encoding to Opus: %v", err)
+			return
+		}
+		packet := append([]byte(nil), buf[:n]...)
+
+		select {
+		case <-syn.reply.ctx.Done():
+			// Drop what discordgo buffered so the voice stops within a frame
+			for len(vc.OpusSend) > 0 {
+				select {
+				case <-vc.OpusSend:
+				default:
+				}
+			}
+			syn.reply.addSpoken(playedPart(syn.text, i, frames))
+			return
+		case vc.OpusSend <- packet:
+		}
 	}
 
-	return opusData[:encoded], nil
+	syn.reply.addSpoken(syn.text)
+}
+
+// playedPart estimates the words of text heard when playback stopped after
+// played of total packets.
+func playedPart(text string, played, total int) string {
+	words := strings.Fields(text)
+	return strings.Join(words[:len(words)*played/total], " ")
 }
@@ -0,0 +1,57 @@
+package voice
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minSentenceLength keeps abbreviations and short interjections attached to
+// the following sentence so TTS is not called for a handful of characters.
+const minSentenceLength = 20
+
+// sentenceSplitter cuts a streamed answer into sentences as soon as they are
+// complete so they can be spoken while the rest is still being generated.
+type sentenceSplitter struct {
+	buffer strings.Builder
+}
+
+// Write appends a fragment and returns the sentences it completed.
+func (sp *sentenceSplitter) Write(delta string) []string {
+	sp.buffer.WriteString(delta)
+
+	var sentences []string
+	text := sp.buffer.String()
+	start := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes)-1; i++ {
+		// A period needs a space after it to end a sentence, as in "3.14"
+		if runes[i] != '\n' && (!isSentenceEnd(runes[i]) || !unicode.IsSpace(runes[i+1])) {
+			continue
+		}
+		sentence := strings.TrimSpace(string(runes[start : i+1]))
+		if len([]rune(sentence)) < minSentenceLength {
+			continue
+		}
+		sentences = append(sentences, sentence)
+		start = i + 1
+	}
+
+	sp.buffer.Reset()
+	sp.buffer.WriteString(string(runes[start:]))
+	return sentences
+}
+
+// Flush returns whatever text is left once the stream has ended.
+func (sp *sentenceSplitter) Flush() string {
+	rest := strings.TrimSpace(sp.buffer.String())
+	sp.buffer.Reset()
+	return rest
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', '\n', '…':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package voice
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSentenceSplitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		want   []string
+		rest   string
+	}{
+		{
+			name:   "whole sentences",
+			deltas: []string{"This is the first sentence. And this is the second one! Trailing"},
+			want:   []string{"This is the first sentence.", "And this is the second one!"},
+			rest:   "Trailing",
+		},
+		{
+			name:   "split across deltas",
+			deltas: []string{"Humor setting is", " at seventy-five", " percent. Okay", "?"},
+			want:   []string{"Humor setting is at seventy-five percent."},
+			rest:   "Okay?",
+		},
+		{
+			name:   "short sentences stay attached",
+			deltas: []string{"Hi. Ok. That is all there is to it. "},
+			want:   []string{"Hi. Ok. That is all there is to it."},
+		},
+		{
+			name:   "no split inside numbers",
+			deltas: []string{"Pi is roughly 3.14159 as you know. "},
+			want:   []string{"Pi is roughly 3.14159 as you know."},
+		},
+		{
+			name:   "newlines end sentences",
+			deltas: []string{"First item of the list\nSecond item of the list\n"},
+			want:   []string{"First item of the list"},
+			rest:   "Second item of the list",
+		},
+		{
+			name:   "end needs following space",
+			deltas: []string{"This sentence is not over yet."},
+			rest:   "This sentence is not over yet.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp := &sentenceSplitter{}
+			var got []string
+			for _, delta := range tt.deltas {
+				got = append(got, sp.Write(delta)...)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got sentences %q, want %q", got, tt.want)
+			}
+			if rest := sp.Flush(); rest != tt.rest {
+				t.Errorf("got rest %q, want %q", rest, tt.rest)
+			}
+			if rest := sp.Flush(); rest != "" {
+				t.Errorf("second flush returned %q", rest)
+			}
+		})
+	}
+}
+
+func TestSentenceSplitterKeepsText(t *testing.T) {
+	text := "Absolute honesty isn't always the most diplomatic. Nor the safest form of communication with emotional beings! Ninety percent it is. "
+	sp := &sentenceSplitter{}
+	var got []string
+	for _, r := range text {
+		got = append(got, sp.Write(string(r))...)
+	}
+	if rest := sp.Flush(); rest != "" {
+		got = append(got, rest)
+	}
+	if strings.Join(got, " ") != strings.TrimSpace(text) {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// transcriptFlushInterval batches lines so a busy channel costs one
+	// message every few seconds rather than one per utterance
+	transcriptFlushInterval = 3 * time.Second
+	maxTranscriptMessage    = 2000
+	// transcriptArchiveMinutes is how long a transcript thread stays open without activity
+	transcriptArchiveMinutes = 1440
+)
+
+// Transcript modes
+const (
+	TranscriptOff     = "off"
+	TranscriptChannel = "channel"
+	TranscriptThread  = "thread"
+)
+
+// transcriptSink posts what was said in the voice channel to a text channel.
+type transcriptSink struct {
+	session   *discordgo.Session
+	channelID string
+
+	mutex   sync.Mutex
+	pending []string
+}
+
+// EnableTranscript starts posting utterances and replies to textChannelID,
+// or to a new thread in it, depending on the configured transcript mode.
+func (vc *VoiceConnection) EnableTranscript(textChannelID string) error {
+	channelID := textChannelID
+	switch vc.Config.VoiceTranscript {
+	case TranscriptOff, "":
+		return nil
+	case TranscriptThread:
+		name := "Voice transcript " + time.Now().Format("2006-01-02 15:04")
+		thread, err := vc.Session.ThreadStart(textChannelID, name, discordgo.ChannelTypeGuildPublicThread, transcriptArchiveMinutes)
+		if err != nil {
+			return fmt.Errorf("failed to create transcript thread: %w", err)
+		}
+		channelID = thread.ID
+	case TranscriptChannel:
+	default:
+		return fmt.Errorf("unknown transcript mode %q", vc.Config.VoiceTranscript)
+	}
+
+	sink := &transcriptSink{session: vc.Session, channelID: channelID}
+	vc.Mutex.Lock()
+	vc.transcript = sink
+	vc.Mutex.Unlock()
+
+	go sink.run(vc.Context)
+	return nil
+}
+
+// addTranscript records a line of the conversation if a transcript is enabled.
+func (vc *VoiceConnection) addTranscript(speaker, text string) {
+	vc.Mutex.Lock()
+	sink := vc.transcript
+	vc.Mutex.Unlock()
+
+	if sink != nil {
+		sink.add(speaker, text)
+	}
+}
+
+func (t *transcriptSink) add(speaker, text string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.pending = append(t.pending, fmt.Sprintf("**%s:** %s", speaker, text))
+}
+
+// run flushes pending lines periodically until ctx is cancelled, then once more.
+func (t *transcriptSink) run(ctx context.Context) {
+	ticker := time.NewTicker(transcriptFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush()
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+// flush posts pending lines, packing as many as fit in each message.
+func (t *transcriptSink) flush() {
+	t.mutex.Lock()
+	lines := t.pending
+	t.pending = nil
+	t.mutex.Unlock()
+
+	var message []rune
+	for _, line := range lines {
+		runes := []rune(line)
+		if len(runes) > maxTranscriptMessage {
+			runes = append(runes[:maxTranscriptMessage-1], '…')
+		}
+		if len(message) > 0 && len(message)+1+len(runes) > maxTranscriptMessage {
+			t.send(string(message))
+			message = message[:0]
+		}
+		if len(message) > 0 {
+			message = append(message, '\n')
+		}
+		message = append(message, runes...)
+	}
+	if len(message) > 0 {
+		t.send(string(message))
+	}
+}
+
+func (t *transcriptSink) send(content string) {
+	_, err := t.session.ChannelMessageSendComplex(t.channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error posting transcript: %v", err)
+	}
+}
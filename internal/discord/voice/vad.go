@@ -0,0 +1,139 @@
+package voice
+
+import (
+	"math"
+	"time"
+
+	"tars-bot/internal/config"
+)
+
+// bargeInSpeech is how much speech an utterance needs before it counts as
+// the user talking, so clicks and coughs do not cut the bot off.
+const bargeInSpeech = 60 * time.Millisecond
+
+// prerollFrames is how many frames before the start of speech are kept so
+// the first syllable is not clipped.
+const prerollFrames = 10
+
+// frame is one received Opus packet and its decoded PCM.
+type frame struct {
+	opus []byte
+	pcm  []int16
+}
+
+// duration returns the playback length of interleaved stereo PCM.
+func (f frame) duration() time.Duration {
+	return time.Duration(len(f.pcm)/channels) * time.Second / sampleRate
+}
+
+// endpointer detects where a speaker's utterances start and end. Speech
+// starts on the first frame whose energy crosses the threshold and ends after
+// a run of silence, either quiet frames or no packets at all: Discord stops
+// transmitting shortly after a user stops talking.
+type endpointer struct {
+	silenceTimeout  time.Duration
+	minUtterance    time.Duration
+	maxUtterance    time.Duration
+	energyThreshold float64
+	// onSpeech is called once per utterance when the speaker is clearly talking
+	onSpeech func()
+
+	preroll    []frame
+	frames     []frame
+	inSpeech   bool
+	speech     time.Duration
+	length     time.Duration
+	silence    time.Duration
+	lastPacket time.Time
+	announced  bool
+}
+
+func newEndpointer(cfg *config.Config, onSpeech func()) *endpointer {
+	return &endpointer{
+		silenceTimeout:  cfg.VADSilenceTimeout,
+		minUtterance:    cfg.MinUtterance,
+		maxUtterance:    cfg.MaxUtterance,
+		energyThreshold: cfg.VADEnergyThreshold,
+		onSpeech:        onSpeech,
+	}
+}
+
+// Push adds a received frame and returns a complete utterance once the
+// speaker has finished, or nil.
+func (e *endpointer) Push(f frame, now time.Time) []frame {
+	e.lastPacket = now
+	voiced := rms(f.pcm) >= e.energyThreshold
+
+	if !e.inSpeech {
+		if !voiced {
+			e.preroll = append(e.preroll, f)
+			if len(e.preroll) > prerollFrames {
+				e.preroll = e.preroll[1:]
+			}
+			return nil
+		}
+
+		e.inSpeech = true
+		for _, p := range e.preroll {
+			e.frames = append(e.frames, p)
+			e.length += p.duration()
+		}
+		e.preroll = nil
+	}
+
+	e.frames = append(e.frames, f)
+	e.length += f.duration()
+	if voiced {
+		e.speech += f.duration()
+		e.silence = 0
+		if !e.announced && e.speech >= bargeInSpeech {
+			e.announced = true
+			e.onSpeech()
+		}
+	} else {
+		e.silence += f.duration()
+	}
+
+	if e.silence >= e.silenceTimeout || e.length >= e.maxUtterance {
+		return e.end()
+	}
+	return nil
+}
+
+// Tick ends the current utterance if no packets arrived for the silence
+// timeout, and returns it or nil.
+func (e *endpointer) Tick(now time.Time) []frame {
+	if !e.inSpeech || now.Sub(e.lastPacket) < e.silenceTimeout {
+		return nil
+	}
+	return e.end()
+}
+
+// end resets the endpointer, returning the buffered utterance unless it held
+// too little speech to be worth transcribing.
+func (e *endpointer) end() []frame {
+	frames, speech := e.frames, e.speech
+
+	e.frames = nil
+	e.inSpeech = false
+	e.announced = false
+	e.speech, e.length, e.silence = 0, 0, 0
+
+	if speech < e.minUtterance {
+		return nil
+	}
+	return frames
+}
+
+// rms returns the root mean square amplitude of pcm.
+func rms(pcm []int16) float64 {
+	if len(pcm) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, sample := range pcm {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(pcm)))
+}
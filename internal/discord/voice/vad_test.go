@@ -0,0 +1,148 @@
+package voice
+
+import (
+	"testing"
+	"time"
+)
+
+const testFrame = 20 * time.Millisecond
+
+func voicedFrame() frame {
+	pcm := make([]int16, frameSamples*channels)
+	for i := range pcm {
+		pcm[i] = 3000
+		if i%2 == 1 {
+			pcm[i] = -3000
+		}
+	}
+	return frame{pcm: pcm}
+}
+
+func silentFrame() frame {
+	return frame{pcm: make([]int16, frameSamples*channels)}
+}
+
+func newTestEndpointer(onSpeech func()) *endpointer {
+	if onSpeech == nil {
+		onSpeech = func() {}
+	}
+	return &endpointer{
+		silenceTimeout:  200 * time.Millisecond,
+		minUtterance:    100 * time.Millisecond,
+		maxUtterance:    time.Second,
+		energyThreshold: 500,
+		onSpeech:        onSpeech,
+	}
+}
+
+// push feeds n frames starting at *now, advancing it, and returns the
+// utterances completed along the way.
+func push(e *endpointer, f frame, n int, now *time.Time) [][]frame {
+	var utterances [][]frame
+	for i := 0; i < n; i++ {
+		if u := e.Push(f, *now); u != nil {
+			utterances = append(utterances, u)
+		}
+		*now = now.Add(testFrame)
+	}
+	return utterances
+}
+
+func TestFrameDuration(t *testing.T) {
+	if d := voicedFrame().duration(); d != testFrame {
+		t.Errorf("got %v, want %v", d, testFrame)
+	}
+}
+
+func TestEndpointerSilenceTimeout(t *testing.T) {
+	now := time.Unix(0, 0)
+	e := newTestEndpointer(nil)
+
+	if u := push(e, silentFrame(), 20, &now); len(u) != 0 {
+		t.Fatal("silence alone produced an utterance")
+	}
+	if u := push(e, voicedFrame(), 10, &now); len(u) != 0 {
+		t.Fatal("utterance ended while still speaking")
+	}
+	if u := push(e, silentFrame(), 9, &now); len(u) != 0 {
+		t.Fatal("utterance ended before the silence timeout")
+	}
+
+	u := push(e, silentFrame(), 1, &now)
+	if len(u) != 1 {
+		t.Fatalf("got %d utterances after the silence timeout, want 1", len(u))
+	}
+	// Preroll, speech and trailing silence
+	if want := prerollFrames + 10 + 10; len(u[0]) != want {
+		t.Errorf("got %d frames, want %d", len(u[0]), want)
+	}
+}
+
+func TestEndpointerPacketGap(t *testing.T) {
+	now := time.Unix(0, 0)
+	e := newTestEndpointer(nil)
+
+	push(e, voicedFrame(), 10, &now)
+	if u := e.Tick(now.Add(100 * time.Millisecond)); u != nil {
+		t.Fatal("utterance ended before the gap reached the silence timeout")
+	}
+	u := e.Tick(now.Add(200 * time.Millisecond))
+	if len(u) != 10 {
+		t.Fatalf("got %d frames after a packet gap, want 10", len(u))
+	}
+	if u := e.Tick(now.Add(time.Second)); u != nil {
+		t.Error("ended the same utterance twice")
+	}
+}
+
+func TestEndpointerMinUtterance(t *testing.T) {
+	now := time.Unix(0, 0)
+	e := newTestEndpointer(nil)
+
+	// 80ms of speech is a cough, not an utterance
+	push(e, voicedFrame(), 4, &now)
+	if u := push(e, silentFrame(), 10, &now); len(u) != 0 {
+		t.Error("kept an utterance shorter than the minimum")
+	}
+
+	push(e, voicedFrame(), 5, &now)
+	if u := push(e, silentFrame(), 10, &now); len(u) != 1 {
+		t.Error("dropped an utterance of the minimum length")
+	}
+}
+
+func TestEndpointerMaxUtterance(t *testing.T) {
+	now := time.Unix(0, 0)
+	e := newTestEndpointer(nil)
+
+	u := push(e, voicedFrame(), 120, &now)
+	if len(u) != 2 {
+		t.Fatalf("got %d utterances from 2.4s of speech, want 2", len(u))
+	}
+	for i, frames := range u {
+		if len(frames) != 50 {
+			t.Errorf("utterance %d has %d frames, want 50", i, len(frames))
+		}
+	}
+}
+
+func TestEndpointerAnnouncesSpeechOnce(t *testing.T) {
+	now := time.Unix(0, 0)
+	calls := 0
+	e := newTestEndpointer(func() { calls++ })
+
+	push(e, voicedFrame(), 2, &now)
+	if calls != 0 {
+		t.Fatal("announced speech before the barge-in threshold")
+	}
+	push(e, voicedFrame(), 20, &now)
+	if calls != 1 {
+		t.Fatalf("announced speech %d times, want 1", calls)
+	}
+
+	push(e, silentFrame(), 10, &now)
+	push(e, voicedFrame(), 5, &now)
+	if calls != 2 {
+		t.Errorf("announced the next utterance %d times in total, want 2", calls)
+	}
+}
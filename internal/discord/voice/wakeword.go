@@ -0,0 +1,127 @@
+package voice
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// minFuzzyLength is the shortest wake phrase, in letters, matched with
+	// any tolerance. Shorter ones must be heard exactly.
+	minFuzzyLength = 4
+	// minEditLength is the shortest wake phrase allowed a dropped or added
+	// letter. Shorter ones only tolerate misheard letters, as a dropped one
+	// turns "tars" into "tar".
+	minEditLength = 5
+)
+
+// greetings may precede a wake word, as in "hey TARS".
+var greetings = map[string]bool{
+	"hey": true, "hi": true, "hello": true, "ok": true, "okay": true, "yo": true,
+}
+
+// wakeWordMatcher recognizes transcriptions addressed to the bot. Speech
+// recognition rarely spells a name consistently, so phrases of at least
+// minFuzzyLength letters match within an edit distance of tolerance times
+// their length, as long as the first letter is right. That keeps "tars"
+// clear of "cars" and "stars" while accepting "tarz".
+type wakeWordMatcher struct {
+	phrases   [][]string
+	tolerance float64
+}
+
+func newWakeWordMatcher(phrases []string, tolerance float64) *wakeWordMatcher {
+	m := &wakeWordMatcher{tolerance: tolerance}
+	for _, phrase := range phrases {
+		if words := normalizeWords(strings.Fields(phrase)); len(words) > 0 {
+			m.phrases = append(m.phrases, words)
+		}
+	}
+	return m
+}
+
+// Match reports whether text starts with a wake phrase, optionally after a
+// greeting, and returns the text following it.
+func (m *wakeWordMatcher) Match(text string) (string, bool) {
+	fields := strings.Fields(text)
+	words := normalizeWords(fields)
+
+	starts := []int{0}
+	if len(words) > 0 && greetings[words[0]] {
+		starts = append(starts, 1)
+	}
+
+	for _, start := range starts {
+		for _, phrase := range m.phrases {
+			end := start + len(phrase)
+			if end > len(words) {
+				continue
+			}
+
+			heard := strings.Join(words[start:end], "")
+			want := strings.Join(phrase, "")
+			if m.matches(heard, want) {
+				rest := strings.Join(fields[end:], " ")
+				return strings.TrimLeft(rest, ",.!?;: "), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// matches reports whether heard is close enough to the wake phrase want.
+func (m *wakeWordMatcher) matches(heard, want string) bool {
+	if heard == want {
+		return true
+	}
+
+	length := len([]rune(want))
+	if length < minFuzzyLength || heard == "" || []rune(heard)[0] != []rune(want)[0] {
+		return false
+	}
+	if length < minEditLength && len([]rune(heard)) != length {
+		return false
+	}
+
+	return editDistance(heard, want) <= int(float64(length)*m.tolerance)
+}
+
+// normalizeWords lowercases words and strips everything but letters and
+// digits, so "TARS," and "Tar's" both become "tars".
+func normalizeWords(fields []string) []string {
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		words = append(words, strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, field))
+	}
+	return words
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
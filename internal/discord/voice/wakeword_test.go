@@ -0,0 +1,100 @@
+package voice
+
+import (
+	"tars-bot/internal/config"
+	"testing"
+)
+
+func TestWakeWordMatcher(t *testing.T) {
+	m := newWakeWordMatcher([]string{"TARS", "Computer"}, 0.25)
+
+	tests := []struct {
+		text  string
+		rest  string
+		match bool
+	}{
+		{"TARS, what time is it?", "what time is it?", true},
+		{"tars", "", true},
+		{"Hey TARS. Tell me a joke", "Tell me a joke", true},
+		{"Tar's humor setting", "humor setting", true},
+		{"Computer, lights", "lights", true},
+		{"Komputer lights", "", false},
+		{"Computor, lights", "lights", true},
+		{"Compute lights", "lights", true},
+		{"cars are fast", "", false},
+		{"bars close at two", "", false},
+		{"stars are out", "", false},
+		{"tar is sticky", "", false},
+		{"hey cars", "", false},
+		{"what time is it TARS", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			rest, ok := m.Match(tt.text)
+			if ok != tt.match {
+				t.Fatalf("Match(%q) = %v, want %v", tt.text, ok, tt.match)
+			}
+			if rest != tt.rest {
+				t.Errorf("Match(%q) rest = %q, want %q", tt.text, rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestWakeWordMatcherDefaults(t *testing.T) {
+	t.Setenv("WAKE_WORDS", "")
+	t.Setenv("WAKE_WORD_TOLERANCE", "")
+	cfg := config.Load()
+	m := newWakeWordMatcher(cfg.WakeWords, cfg.WakeWordTolerance)
+
+	tests := []struct {
+		text  string
+		match bool
+	}{
+		{"TARS, what time is it?", true},
+		{"Hey Tars, tell me a joke", true},
+		{"Tarz, what time is it?", true},
+		{"Taurs what time is it", false},
+		{"tar is sticky", false},
+		{"cars are fast", false},
+		{"stars are out", false},
+	}
+	for _, tt := range tests {
+		if _, ok := m.Match(tt.text); ok != tt.match {
+			t.Errorf("Match(%q) = %v, want %v", tt.text, ok, tt.match)
+		}
+	}
+}
+
+func TestWakeWordTolerance(t *testing.T) {
+	strict := newWakeWordMatcher([]string{"Computer"}, 0)
+	if _, ok := strict.Match("Computor lights"); ok {
+		t.Error("zero tolerance matched a misspelling")
+	}
+
+	loose := newWakeWordMatcher([]string{"Computer"}, 0.5)
+	if _, ok := loose.Match("Commuter lights"); !ok {
+		t.Error("loose tolerance rejected a near match")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"tars", "tars", 0},
+		{"tars", "cars", 1},
+		{"tars", "stars", 1},
+		{"tars", "", 4},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package voice
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	watchInterval = 5 * time.Second
+	// reconnectAfter is how long the voice session may stay down before the
+	// watcher rejoins by itself instead of waiting on discordgo's retries
+	reconnectAfter = 30 * time.Second
+)
+
+// touch records activity, postponing the idle auto-leave.
+func (vc *VoiceConnection) touch() {
+	vc.lastActivity.Store(time.Now().UnixNano())
+}
+
+// watch leaves the channel once it has been idle for the configured time and
+// rejoins when the voice session dropped and did not come back by itself.
+func (vc *VoiceConnection) watch() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var downSince time.Time
+	for {
+		select {
+		case <-vc.Context.Done():
+			return
+		case now := <-ticker.C:
+			idle := now.Sub(time.Unix(0, vc.lastActivity.Load()))
+			if timeout := vc.Config.VoiceIdleTimeout; timeout > 0 && idle >= timeout {
+				log.Printf("Leaving voice in guild %s after %v idle", vc.GuildID, idle.Round(time.Second))
+				vc.Disconnect()
+				return
+			}
+
+			if !vc.hasHumans() {
+				log.Printf("Leaving voice in guild %s, no one left to talk to", vc.GuildID)
+				vc.Disconnect()
+				return
+			}
+
+			if vc.connected() {
+				downSince = time.Time{}
+				continue
+			}
+			if downSince.IsZero() {
+				log.Printf("Voice connection in guild %s lost", vc.GuildID)
+				downSince = now
+				continue
+			}
+			if now.Sub(downSince) >= reconnectAfter {
+				err := vc.reconnect()
+				if err != nil {
+					log.Printf("Error reconnecting voice in guild %s: %v", vc.GuildID, err)
+					continue
+				}
+				downSince = time.Time{}
+			}
+		}
+	}
+}
+
+// HandleVoiceStateUpdate follows the bot when it is moved to another channel
+// and leaves when it was disconnected or the last human left its channel.
+func (vc *VoiceConnection) HandleVoiceStateUpdate(vsu *discordgo.VoiceStateUpdate) {
+	if vsu.UserID == vc.Session.State.User.ID {
+		if vsu.ChannelID == "" {
+			log.Printf("Disconnected from voice in guild %s", vc.GuildID)
+			vc.Disconnect()
+			return
+		}
+
+		vc.Mutex.Lock()
+		if vsu.ChannelID != vc.ChannelID {
+			log.Printf("Moved to voice channel %s in guild %s", vsu.ChannelID, vc.GuildID)
+			vc.ChannelID = vsu.ChannelID
+		}
+		vc.Mutex.Unlock()
+	}
+
+	if !vc.hasHumans() {
+		log.Printf("Leaving voice in guild %s, no one left to talk to", vc.GuildID)
+		vc.Disconnect()
+	}
+}
+
+// hasHumans reports whether anyone but bots is in the bot's channel. Without
+// a cached guild it assumes so rather than leaving.
+func (vc *VoiceConnection) hasHumans() bool {
+	vc.Mutex.Lock()
+	channelID := vc.ChannelID
+	vc.Mutex.Unlock()
+
+	state := vc.Session.State
+	guild, err := state.Guild(vc.GuildID)
+	if err != nil {
+		return true
+	}
+
+	state.RLock()
+	defer state.RUnlock()
+
+	for _, vs := range guild.VoiceStates {
+		if vs.ChannelID != channelID || vs.UserID == state.User.ID {
+			continue
+		}
+		if vs.Member != nil && vs.Member.User != nil && vs.Member.User.Bot {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// connected reports whether the voice session is up. discordgo forgets the
+// connection when it gives up on it, so that counts as down too.
+func (vc *VoiceConnection) connected() bool {
+	vc.Mutex.Lock()
+	voiceConn := vc.VoiceConnection
+	vc.Mutex.Unlock()
+	if voiceConn == nil {
+		return false
+	}
+
+	vc.Session.RLock()
+	current := vc.Session.VoiceConnections[vc.GuildID]
+	vc.Session.RUnlock()
+	if current != voiceConn {
+		return false
+	}
+
+	voiceConn.RLock()
+	defer voiceConn.RUnlock()
+	return voiceConn.Ready
+}
+
+// reconnect rejoins the channel and restarts the receiver and sender on the
+// new voice session.
+func (vc *VoiceConnection) reconnect() error {
+	vc.Mutex.Lock()
+	defer vc.Mutex.Unlock()
+
+	log.Printf("Reconnecting voice in guild %s", vc.GuildID)
+	vc.stopAudio()
+	if vc.VoiceConnection != nil {
+		vc.VoiceConnection.Close()
+	}
+
+	voiceConn, err := vc.Session.ChannelVoiceJoin(vc.GuildID, vc.ChannelID, false, false)
+	if err != nil {
+		return fmt.Errorf("failed to rejoin voice channel: %w", err)
+	}
+	vc.VoiceConnection = voiceConn
+
+	return vc.startAudio()
+}
@@ -0,0 +1,58 @@
+package models
+
+import "math"
+
+// STTOptions tune a transcription. Zero values use the backend defaults.
+type STTOptions struct {
+	// Language is an ISO-639-1 hint such as "en"
+	Language string
+	// Prompt biases recognition towards its vocabulary, e.g. names
+	Prompt string
+	// ResponseFormat is json (text only) or verbose_json (with segments)
+	ResponseFormat string
+}
+
+// Transcription is the recognized text of an upload. Segments are only
+// filled in for the verbose_json response format.
+type Transcription struct {
+	Text     string                 `json:"text"`
+	Language string                 `json:"language"`
+	Duration float64                `json:"duration"`
+	Segments []TranscriptionSegment `json:"segments"`
+}
+
+// TranscriptionSegment is a stretch of the transcription with its timing in
+// seconds and the model's own quality estimates.
+type TranscriptionSegment struct {
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	Text         string  `json:"text"`
+	AvgLogProb   float64 `json:"avg_logprob"`
+	NoSpeechProb float64 `json:"no_speech_prob"`
+}
+
+// Confidence estimates how likely the transcription is real speech that was
+// recognized correctly, from 0 to 1: the mean per-token probability of each
+// segment, discounted by its no-speech probability and weighted by duration.
+// Without segments there is nothing to judge by and it returns 1.
+func (t Transcription) Confidence() float64 {
+	var total, weights float64
+	for _, segment := range t.Segments {
+		weight := math.Max(segment.End-segment.Start, 0.01)
+		total += weight * math.Exp(segment.AvgLogProb) * (1 - segment.NoSpeechProb)
+		weights += weight
+	}
+	if weights == 0 {
+		return 1
+	}
+	return total / weights
+}
+
+// TTSOptions select how text is spoken. Zero values use the backend defaults.
+type TTSOptions struct {
+	Model string
+	Voice string
+	Speed float64
+	// Format is the response format, wav or pcm (raw 24kHz 16-bit mono)
+	Format string
+}